@@ -0,0 +1,242 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+	"sync"
+)
+
+var (
+	distance  = flag.Float64("distance", 100, "Distance, in millimetres, the mouse is moved along the ruler.")
+	traceFile = flag.String("trace", "", "Measure the strokes in this trace rather than the mouse: two strokes (X, then Y) for each enabled DPI stage, separated by pauses.")
+	record    = flag.String("record", "", "Record the strokes to this trace file.")
+	profile   = flag.Int("profile", 1, "The profile whose DPI stages are measured, with -trace or unless the active one can be read (with "+device.ExperimentalEnv+").")
+)
+
+type stage struct {
+	stage device.DPIStage
+	dpi   [2]device.DPI
+}
+
+// enabledStages returns the enabled DPI stages of a profile.
+func enabledStages(cfg *device.Config, profile device.ProfileID) []stage {
+	var stages []stage
+	for i, dpi := range cfg.Profiles[profile-1].DPIValues() {
+		if dpi[0] != 0 {
+			stages = append(stages, stage{device.DPIStage(i + 1), dpi})
+		}
+	}
+
+	return stages
+}
+
+// recorder keeps the movement of the mouse while a stroke is measured.
+type recorder struct {
+	mu      sync.Mutex
+	active  bool
+	current stroke
+}
+
+func (self *recorder) follow(events <-chan device.Event) {
+	for ev := range events {
+		if ev.Kind != device.MotionEvent {
+			continue
+		}
+
+		self.mu.Lock()
+		if self.active {
+			self.current = append(self.current, ev)
+		}
+		self.mu.Unlock()
+	}
+}
+
+func (self *recorder) start() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.active = true
+	self.current = nil
+}
+
+func (self *recorder) stop() stroke {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.active = false
+	return self.current
+}
+
+func calibrateTrace(stages []stage) []result {
+	f, err := os.Open(*traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := device.ReadTrace(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	strokes := splitStrokes(events)
+	if len(strokes) != 2*len(stages) {
+		log.Fatalf("Found %v strokes in the trace, expected %v for %v enabled DPI stages", len(strokes), 2*len(stages), len(stages))
+	}
+
+	var results []result
+	for i, s := range stages {
+		results = append(results, measure(s.stage, s.dpi, strokes[2*i], strokes[2*i+1], *distance))
+	}
+
+	return results
+}
+
+func calibrateMouse(dev *device.Device, stages []stage) []result {
+	var out *os.File
+	if *record != "" {
+		var err error
+		if out, err = os.Create(*record); err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := dev.Events(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rec := new(recorder)
+	go rec.follow(events)
+
+	stdin := bufio.NewReader(os.Stdin)
+	wait := func(prompt string, args ...interface{}) {
+		fmt.Printf(prompt, args...)
+		if _, err := stdin.ReadString('\n'); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Switching DPI stage relies on a guessed address; without the
+	// experimental features, the user selects each stage with the DPI
+	// button instead.
+	switchStages := device.Experimental()
+	if switchStages {
+		original, err := dev.ActiveDPIStage()
+		if err != nil {
+			log.Fatalf("Unable to read the active DPI stage: %v", err)
+		}
+		defer func() {
+			if err := dev.SetDPIStage(original); err != nil {
+				log.Printf("Unable to switch back to DPI stage %v: %v", original, err)
+			}
+		}()
+	}
+
+	var results []result
+	for _, s := range stages {
+		if switchStages {
+			if err := dev.SetDPIStage(s.stage); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			wait("Select DPI stage %v (%vx%v) with the DPI button, then press Enter.", s.stage, s.dpi[0], s.dpi[1])
+		}
+
+		var strokes [2]stroke
+		for axis, direction := range []string{"right", "towards you"} {
+			wait("DPI stage %v (%vx%v): put the mouse at the start of the ruler, then press Enter.", s.stage, s.dpi[0], s.dpi[1])
+			rec.start()
+			wait("Move the mouse %v mm %v along the ruler, then press Enter.", *distance, direction)
+			strokes[axis] = rec.stop()
+
+			if out != nil {
+				for _, ev := range strokes[axis] {
+					if err := device.WriteTraceEvent(out, ev); err != nil {
+						log.Fatal(err)
+					}
+				}
+			}
+		}
+
+		results = append(results, measure(s.stage, s.dpi, strokes[0], strokes[1], *distance))
+	}
+
+	return results
+}
+
+func main() {
+	flag.Parse()
+
+	if *distance <= 0 {
+		log.Fatalf("Invalid value for -distance: %v", *distance)
+	}
+
+	// The configuration cannot be read from the mouse.
+	cfg, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		log.Print("No configuration was written by these tools; comparing with the default DPI stages.")
+		cfg = device.NewConfig()
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if *traceFile != "" {
+		p, err := device.NewProfileID(*profile)
+		if err != nil {
+			log.Fatalf("Invalid value for -profile: %v", err)
+		}
+
+		printResults(os.Stdout, calibrateTrace(enabledStages(cfg, p)))
+		return
+	}
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	active, err := dev.ActiveProfile()
+	if errors.Is(err, device.ErrExperimental) {
+		if active, err = device.NewProfileID(*profile); err != nil {
+			log.Fatalf("Invalid value for -profile: %v", err)
+		}
+		log.Printf("Measuring the DPI stages of profile %v; make sure it is the active one.", active)
+	} else if err != nil {
+		log.Fatalf("Unable to read the active profile: %v", err)
+	}
+
+	printResults(os.Stdout, calibrateMouse(dev, enabledStages(cfg, active)))
+}
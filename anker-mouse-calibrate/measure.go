@@ -0,0 +1,120 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const mmPerInch = 25.4
+
+// In traces, the strokes along the ruler are told apart by the pause
+// between them.
+const strokeGap = 500 * time.Millisecond
+
+// stroke is the movement recorded while the mouse is moved along the
+// ruler.
+type stroke []device.Event
+
+func (self stroke) counts() (x, y int) {
+	for _, ev := range self {
+		x += ev.X
+		y += ev.Y
+	}
+
+	return x, y
+}
+
+// splitStrokes splits the motion events of a trace into strokes.
+func splitStrokes(events []device.Event) []stroke {
+	var strokes []stroke
+	var current stroke
+
+	for _, ev := range events {
+		if ev.Kind != device.MotionEvent {
+			continue
+		}
+
+		if len(current) > 0 && ev.Time.Sub(current[len(current)-1].Time) >= strokeGap {
+			strokes = append(strokes, current)
+			current = nil
+		}
+		current = append(current, ev)
+	}
+
+	if len(current) > 0 {
+		strokes = append(strokes, current)
+	}
+
+	return strokes
+}
+
+// result is the measurement of a DPI stage, with a stroke along each
+// axis.
+type result struct {
+	stage      device.DPIStage
+	configured [2]device.DPI
+	measured   [2]float64
+}
+
+func measure(stage device.DPIStage, configured [2]device.DPI, x, y stroke, distance float64) result {
+	cx, _ := x.counts()
+	_, cy := y.counts()
+
+	return result{
+		stage:      stage,
+		configured: configured,
+		measured: [2]float64{
+			abs(float64(cx)) * mmPerInch / distance,
+			abs(float64(cy)) * mmPerInch / distance,
+		},
+	}
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func deviation(measured float64, configured device.DPI) string {
+	return fmt.Sprintf("%.0f (%+.1f%%)", measured, 100*(measured-float64(configured))/float64(configured))
+}
+
+func printResults(w io.Writer, results []result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "stage\tconfigured\tmeasured X\tmeasured Y")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%v\t%vx%v\t%v\t%v\n",
+			r.stage, r.configured[0], r.configured[1],
+			deviation(r.measured[0], r.configured[0]),
+			deviation(r.measured[1], r.configured[1]))
+	}
+}
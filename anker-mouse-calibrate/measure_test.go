@@ -0,0 +1,107 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readTestTrace(t *testing.T, name string) []device.Event {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := device.ReadTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestSplitStrokes(t *testing.T) {
+	tests := []struct {
+		trace string
+		want  [][2]int // Counts of each stroke.
+	}{
+		// Buttons and wheel between the strokes are ignored.
+		{"two-stages.jsonl", [][2]int{{3902, 7}, {-4, 3913}, {7913, 12}, {9, 7815}}},
+		// Pauses shorter than strokeGap do not split strokes.
+		{"short-pause.jsonl", [][2]int{{3904, 3916}}},
+		// Any movement during a pause is a stroke of its own.
+		{"jitter-in-pause.jsonl", [][2]int{{3902, 3}, {1, 0}, {2, 3913}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.trace, func(t *testing.T) {
+			var got [][2]int
+			for _, s := range splitStrokes(readTestTrace(t, tt.trace)) {
+				x, y := s.counts()
+				got = append(got, [2]int{x, y})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stroke counts %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStrokesEmpty(t *testing.T) {
+	if strokes := splitStrokes(nil); len(strokes) != 0 {
+		t.Errorf("splitStrokes(nil) = %v, want no strokes", strokes)
+	}
+}
+
+func TestMeasureTrace(t *testing.T) {
+	strokes := splitStrokes(readTestTrace(t, "two-stages.jsonl"))
+	stages := []stage{
+		{1, [2]device.DPI{1000, 1000}},
+		{2, [2]device.DPI{2000, 2000}},
+	}
+
+	var results []result
+	for i, s := range stages {
+		results = append(results, measure(s.stage, s.dpi, strokes[2*i], strokes[2*i+1], 100))
+	}
+
+	var buf bytes.Buffer
+	printResults(&buf, results)
+
+	want := `stage  configured  measured X    measured Y
+1      1000x1000   991 (-0.9%)   994 (-0.6%)
+2      2000x2000   2010 (+0.5%)  1985 (-0.7%)
+`
+	if buf.String() != want {
+		t.Errorf("Results:\n%v\nwant:\n%v", buf.String(), want)
+	}
+}
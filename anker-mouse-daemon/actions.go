@@ -0,0 +1,408 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/evdev"
+	"github.com/godbus/dbus/v5"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHostProfile = "default"
+	dbusCallTimeout    = 10 * time.Second
+)
+
+type dbusCall struct {
+	Bus         string        `json:"bus"` // "session" (default) or "system"
+	Destination string        `json:"destination"`
+	Path        string        `json:"path"`
+	Method      string        `json:"method"` // Including the interface.
+	Args        []interface{} `json:"args"`
+}
+
+// lightCycle sets the next of its colors every time it is run.
+type lightCycle struct {
+	Colors      []colorful.HexColor `json:"colors"`
+	Brightness  *device.Brightness  `json:"brightness"`
+	BreathSpeed device.BreathSpeed  `json:"breath_speed"`
+
+	next int
+}
+
+// action is what to do when a button is pressed. Exactly one of the
+// fields is set.
+type action struct {
+	Launch      []string    `json:"launch"`       // Program and arguments.
+	Script      string      `json:"script"`       // Run with /bin/sh -c.
+	HostProfile string      `json:"host_profile"` // Switch to another set of actions.
+	DBus        *dbusCall   `json:"dbus"`
+	Light       *lightCycle `json:"light"`
+}
+
+// binding gives actions to a button, depending on the host profile.
+// Each binding is assigned one of the host keys, in order. Buttons are
+// numbered as by device.ButtonsProfile.BindKey.
+type binding struct {
+	Profile device.ProfileID   `json:"profile"`
+	Button  int                `json:"button"`
+	Actions map[string]*action `json:"actions"`
+
+	usage uint16 // HID usage bound to the button.
+	key   uint16 // Key code reported by the kernel.
+}
+
+// actions is the format of the -actions file, e.g.:
+//
+//	{
+//	  "bindings": [
+//	    {"profile": 1, "button": 7, "actions": {
+//	      "default": {"launch": ["firefox", "--new-window"]},
+//	      "media": {"script": "playerctl play-pause"}
+//	    }},
+//	    {"profile": 1, "button": 8, "actions": {
+//	      "default": {"host_profile": "media"},
+//	      "media": {"host_profile": "default"}
+//	    }},
+//	    {"profile": 2, "button": 7, "actions": {
+//	      "default": {"light": {"colors": ["#ff0000", "#00ff00", "#0000ff"]}}
+//	    }}
+//	  ]
+//	}
+//
+// Buttons are bound to F13, F14 and so on in the order they are
+// listed; the actions of the "default" host profile are used when the
+// current one has none for the button.
+type actions struct {
+	Bindings []*binding `json:"bindings"`
+
+	// The keys are followed by a goroutine per connection of the
+	// mouse, which can overlap around a reconnection: mu guards the
+	// host profile and the position in the light cycles.
+	mu          sync.Mutex
+	hostProfile string
+
+	// Starts the commands of launch and script actions.
+	startCommand func(cmd *exec.Cmd) error
+}
+
+func (self *action) validate() error {
+	set := 0
+	if len(self.Launch) > 0 {
+		set++
+	}
+	if self.Script != "" {
+		set++
+	}
+	if self.HostProfile != "" {
+		set++
+	}
+	if self.DBus != nil {
+		set++
+		if self.DBus.Bus != "" && self.DBus.Bus != "session" && self.DBus.Bus != "system" {
+			return fmt.Errorf("Unknown D-Bus bus %q", self.DBus.Bus)
+		}
+		if self.DBus.Destination == "" || self.DBus.Path == "" || self.DBus.Method == "" {
+			return fmt.Errorf("D-Bus calls need destination, path and method")
+		}
+	}
+	if self.Light != nil {
+		set++
+		if len(self.Light.Colors) == 0 {
+			return fmt.Errorf("Light cycles need at least one color")
+		}
+		if self.Light.Brightness != nil && !self.Light.Brightness.Valid() {
+			return fmt.Errorf("Invalid brightness: %v", *self.Light.Brightness)
+		}
+		if !self.Light.BreathSpeed.Valid() {
+			return fmt.Errorf("Invalid breath speed: %v", self.Light.BreathSpeed)
+		}
+	}
+
+	if set != 1 {
+		return fmt.Errorf("Actions need exactly one of launch, script, host_profile, dbus or light")
+	}
+
+	return nil
+}
+
+func loadActions(path string) (*actions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &actions{
+		hostProfile:  defaultHostProfile,
+		startCommand: start,
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+
+	keys := device.HostKeys()
+	if len(a.Bindings) > len(keys) {
+		return nil, fmt.Errorf("Only %v buttons can be given actions", len(keys))
+	}
+
+	bound := make(map[[2]int]bool)
+	for i, b := range a.Bindings {
+		if !b.Profile.Valid() {
+			return nil, fmt.Errorf("Invalid profile for binding %v: %v", i, b.Profile)
+		}
+
+		id := [2]int{int(b.Profile), b.Button}
+		if bound[id] {
+			return nil, fmt.Errorf("Button %v of profile %v is bound more than once", b.Button, b.Profile)
+		}
+		bound[id] = true
+
+		if len(b.Actions) == 0 {
+			return nil, fmt.Errorf("Binding %v has no actions", i)
+		}
+		for name, act := range b.Actions {
+			if err := act.validate(); err != nil {
+				return nil, fmt.Errorf("Invalid action %q for binding %v: %v", name, i, err)
+			}
+		}
+
+		b.usage = keys[i]
+		b.key, _ = evdev.KeyFromHIDUsage(b.usage)
+	}
+
+	return a, nil
+}
+
+// bind binds the buttons to their host keys in the configuration.
+func (self *actions) bind(cfg *device.Config) error {
+	for _, b := range self.Bindings {
+		err := cfg.Profiles[b.Profile-1].ButtonsProfile.BindKey(b.Button, b.usage)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *actions) binding(key uint16) *binding {
+	for _, b := range self.Bindings {
+		if b.key == key {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// handle runs the action for a key press, if any.
+func (self *actions) handle(ev evdev.Event, dev *device.Device) {
+	if ev.Type != evdev.EvKey || ev.Value != 1 {
+		return
+	}
+
+	b := self.binding(ev.Code)
+	if b == nil {
+		return
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	act, ok := b.Actions[self.hostProfile]
+	if !ok {
+		act, ok = b.Actions[defaultHostProfile]
+	}
+	if !ok {
+		return
+	}
+
+	if err := self.run(act, dev); err != nil {
+		log.Printf("Error running the action for button %v of profile %v: %v", b.Button, b.Profile, err)
+	}
+}
+
+// run runs an action, with mu held.
+func (self *actions) run(act *action, dev *device.Device) error {
+	switch {
+	case len(act.Launch) > 0:
+		return self.startCommand(exec.Command(act.Launch[0], act.Launch[1:]...))
+
+	case act.Script != "":
+		return self.startCommand(exec.Command("/bin/sh", "-c", act.Script))
+
+	case act.HostProfile != "":
+		log.Printf("Switching to host profile %q", act.HostProfile)
+		self.hostProfile = act.HostProfile
+		return nil
+
+	case act.DBus != nil:
+		go func() {
+			if err := act.DBus.call(); err != nil {
+				log.Printf("Error calling %v: %v", act.DBus.Method, err)
+			}
+		}()
+		return nil
+
+	case act.Light != nil:
+		if dev == nil {
+			return fmt.Errorf("The mouse is not connected")
+		}
+		return act.Light.apply(dev)
+	}
+
+	return nil
+}
+
+// start runs a command in the background, without waiting for it.
+func start(cmd *exec.Cmd) error {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go cmd.Wait()
+	return nil
+}
+
+func (self *dbusCall) call() error {
+	connect := dbus.ConnectSessionBus
+	if self.Bus == "system" {
+		connect = dbus.ConnectSystemBus
+	}
+
+	conn, err := connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// JSON numbers are all float64; integers are more commonly
+	// expected.
+	args := make([]interface{}, len(self.Args))
+	for i, a := range self.Args {
+		if f, ok := a.(float64); ok && f == float64(int32(f)) {
+			a = int32(f)
+		}
+		args[i] = a
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+
+	obj := conn.Object(self.Destination, dbus.ObjectPath(self.Path))
+	return obj.CallWithContext(ctx, self.Method, 0, args...).Err
+}
+
+// apply sets the next color of the cycle; the actions' mu must be
+// held.
+func (self *lightCycle) apply(dev *device.Device) error {
+	c := self.Colors[self.next%len(self.Colors)]
+	self.next++
+
+	brightness := device.MaxBrightness
+	if self.Brightness != nil {
+		brightness = *self.Brightness
+	}
+
+	return dev.SetLight(colorful.Color(c), brightness, self.BreathSpeed)
+}
+
+// readKeys reads the events of all the given event nodes or
+// recordings, until ctx is cancelled or all of them are exhausted.
+func readKeys(ctx context.Context, paths []string) <-chan evdev.Event {
+	out := make(chan evdev.Event)
+	done := make(chan struct{})
+
+	for _, path := range paths {
+		go func(path string) {
+			defer func() { done <- struct{}{} }()
+
+			f, err := os.Open(path)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+
+			// Closing the node interrupts the read.
+			stop := context.AfterFunc(ctx, func() { f.Close() })
+			defer stop()
+			defer f.Close()
+
+			r := evdev.NewReader(f)
+			for {
+				ev, err := r.Read()
+				if err != nil {
+					if err != io.EOF && ctx.Err() == nil {
+						log.Printf("Error reading %v: %v", path, err)
+					}
+					return
+				}
+
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(path)
+	}
+
+	go func() {
+		for range paths {
+			<-done
+		}
+		close(out)
+	}()
+
+	return out
+}
+
+// followKeys runs the actions for the host keys pressed on the mouse,
+// read from its event nodes, or from the given recording.
+func followKeys(ctx context.Context, a *actions, dev *device.Device, recording string) {
+	paths := []string{recording}
+	if recording == "" {
+		var err error
+		paths, err = evdev.Find(device.HoltekVendorId, device.AnkerMouseDeviceId)
+		if err != nil || len(paths) == 0 {
+			log.Printf("Unable to find the input nodes of the mouse: %v", err)
+			return
+		}
+	}
+
+	for ev := range readKeys(ctx, paths) {
+		a.handle(ev, dev)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"github.com/flameeyes/anker-mouse-tool/device/devicetest"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// TestFollowKeysRecording runs the actions for a recording of the host
+// keys: F13 (launch, or a script in the media host profile), F14
+// (switch host profile), F13 again in the media host profile, with an
+// autorepeat, F16 (not bound), F14 back to the default host profile,
+// F15 twice (light cycle), and F13. The light cycle plays back a
+// cassette.
+func TestFollowKeysRecording(t *testing.T) {
+	a, err := loadActions("testdata/actions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var started [][]string
+	a.startCommand = func(cmd *exec.Cmd) error {
+		started = append(started, cmd.Args)
+		return nil
+	}
+
+	dev := devicetest.OpenCassette(t, "testdata/light-cycle.jsonl")
+	followKeys(context.Background(), a, dev, "testdata/host-keys.evdev")
+
+	want := [][]string{
+		{"notify-send", "default"},
+		{"/bin/sh", "-c", "playerctl play-pause"},
+		{"notify-send", "default"},
+	}
+	if !reflect.DeepEqual(started, want) {
+		t.Errorf("Commands started: %q, want %q", started, want)
+	}
+	if a.hostProfile != defaultHostProfile {
+		t.Errorf("Host profile %q, want %q", a.hostProfile, defaultHostProfile)
+	}
+	if next := a.Bindings[2].Actions[defaultHostProfile].Light.next; next != 2 {
+		t.Errorf("Light cycle at %v, want 2", next)
+	}
+}
+
+func TestActionValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		act  action
+	}{
+		{"none", action{}},
+		{"two", action{Script: "true", HostProfile: "media"}},
+		{"dbus without method", action{DBus: &dbusCall{Destination: "org.example", Path: "/"}}},
+		{"empty light cycle", action{Light: &lightCycle{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.act.validate(); err == nil {
+				t.Error("Invalid action accepted")
+			}
+		})
+	}
+}
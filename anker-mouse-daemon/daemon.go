@@ -0,0 +1,392 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	stateFile = flag.String("state", "", "JSON file describing the state to apply every time the mouse is connected.")
+	hooksFile = flag.String("hooks", "", "JSON file describing commands to run, or URLs to post to, on device events.")
+
+	actionsFile = flag.String("actions", "", "JSON file describing actions to run when buttons bound to host keys are pressed.")
+	bind        = flag.Bool("bind", false, "Bind the buttons listed in the -actions file to their host keys in the mouse configuration, then exit.")
+	force       = flag.Bool("force", false, "With -bind, write the configuration even if it could lock the user out.")
+	recording   = flag.String("evdev_recording", "", "Run the -actions for the key presses in this recording of an evdev node, then exit.")
+
+	statsFile    = flag.String("stats", "", "Collect usage statistics (presses, wheel, distance, time per profile) into this local file. The distance per DPI stage needs "+device.ExperimentalEnv+", as does following the profile button.")
+	statsProfile = flag.Int("stats_profile", 0, "Without "+device.ExperimentalEnv+", count the statistics as if this profile were active (default the profile of the -state file, if any); changes made with the profile button go unnoticed.")
+	exportFormat = flag.String("export_stats", "", "Print the statistics in the -stats file as csv, json or svg (a heatmap of the buttons), then exit.")
+
+	metricsAddress = flag.String("metrics_address", "", "Serve Prometheus metrics on /metrics at this address, e.g. :9595.")
+)
+
+// lastConfigOrDefault returns the last configuration written by the
+// tools, or the default one if there is none.
+func lastConfigOrDefault() (*device.Config, error) {
+	cfg, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		return device.NewConfig(), nil
+	}
+
+	return cfg, err
+}
+
+// bindActions writes the last configuration written by the tools, with
+// the buttons of the actions bound to their host keys. Without one, the
+// rest of the configuration is not known, and writing the defaults
+// would silently replace it.
+func bindActions(a *actions) {
+	cfg, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		log.Fatal("No configuration was written by these tools before, so binding the buttons would reset the rest of the configuration of both profiles; write one first, e.g. with anker-mouse-replayer or anker-mouse-reset.")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := a.bind(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	write := cfg.Write
+	if *force {
+		write = cfg.WriteForce
+	}
+
+	if err := write(dev); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.RecordConfig(cfg); err != nil {
+		log.Fatalf("Configuration written, but not recorded: %v", err)
+	}
+}
+
+// sameConfig reports whether writing cfg would leave last unchanged. A
+// configuration without a polling rate leaves the recorded one alone.
+func sameConfig(cfg, last *device.Config) bool {
+	if cfg.PollingRate != 0 && cfg.PollingRate != last.PollingRate {
+		return false
+	}
+
+	return reflect.DeepEqual(cfg.Profiles, last.Profiles)
+}
+
+// restoreState returns the part of the state that needs to be applied
+// to the mouse just connected. Writing the configuration rewrites the
+// flash memory of the mouse, and cannot be read back, so it is skipped
+// when it is the last one written by these tools. The profile is
+// skipped when the mouse reports it as active already, which is only
+// known with the experimental features. The light is temporary, so it
+// is always set again.
+func restoreState(dev *device.Device, state *device.State) *device.State {
+	s := *state
+
+	if s.Config != nil {
+		last, err := device.LoadLastConfig()
+		switch {
+		case err == nil && sameConfig(s.Config, last):
+			s.Config = nil
+		case err != nil && !os.IsNotExist(err):
+			log.Printf("Unable to compare with the last configuration written, writing it again: %v", err)
+		}
+	}
+
+	if s.Profile != nil && device.Experimental() {
+		if p, err := dev.ActiveProfile(); err == nil && p == *s.Profile {
+			s.Profile = nil
+		}
+	}
+
+	return &s
+}
+
+// followEvents runs the hooks, and collects the statistics and metrics,
+// for the events of the device, until ctx is cancelled. Profile and DPI
+// stage changes are only followed with the experimental features.
+func followEvents(ctx context.Context, dev *device.Device, h *hooks, st *statsCollector, m *metrics) {
+	var events <-chan device.Event
+	var err error
+	if device.Experimental() {
+		events, err = dev.EventsWithState(ctx)
+	} else {
+		events, err = dev.Events(ctx)
+	}
+	if err != nil {
+		log.Printf("Unable to follow the device events: %v", err)
+		return
+	}
+
+	if st != nil {
+		st.connected(dev)
+		defer st.disconnected()
+	}
+
+	for ev := range events {
+		if ev.Kind == device.ErrorEvent {
+			log.Print(ev.Error)
+			continue
+		}
+
+		if h != nil {
+			h.run(newDeviceHookEvent(ev))
+		}
+		if st != nil {
+			st.add(ev)
+		}
+		if m != nil {
+			m.add(ev)
+		}
+	}
+}
+
+// saveStats saves the statistics periodically, and on exit.
+func saveStats(st *statsCollector) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(statsSaveInterval)
+	for {
+		select {
+		case <-ticker.C:
+			if err := st.save(); err != nil {
+				log.Printf("Error saving the statistics: %v", err)
+			}
+
+		case <-signals:
+			if err := st.save(); err != nil {
+				log.Fatalf("Error saving the statistics: %v", err)
+			}
+			os.Exit(0)
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Load the state once at start, so that mistakes are reported
+	// right away. It is then loaded again at every connection, to
+	// pick up changes.
+	if *stateFile != "" {
+		if _, err := device.LoadState(*stateFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var h *hooks
+	if *hooksFile != "" {
+		var err error
+		if h, err = loadHooks(*hooksFile); err != nil {
+			log.Fatalf("Invalid hooks file %v: %v", *hooksFile, err)
+		}
+	}
+
+	if *exportFormat != "" {
+		if *statsFile == "" {
+			log.Fatal("-export_stats requires -stats")
+		}
+
+		s, err := loadStats(*statsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := exportStats(os.Stdout, s, *exportFormat); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var st *statsCollector
+	if *statsFile != "" {
+		profile := device.ProfileID(*statsProfile)
+		if profile == 0 && *stateFile != "" {
+			if state, err := device.LoadState(*stateFile); err == nil && state.Profile != nil {
+				profile = *state.Profile
+			}
+		}
+		if profile != 0 && !profile.Valid() {
+			log.Fatalf("Invalid -stats_profile: %v", profile)
+		}
+
+		var err error
+		if st, err = newStatsCollector(*statsFile, profile); err != nil {
+			log.Fatalf("Invalid statistics file %v: %v", *statsFile, err)
+		}
+		go saveStats(st)
+	}
+
+	var m *metrics
+	if *metricsAddress != "" {
+		m = serveMetrics(*metricsAddress)
+	}
+
+	var a *actions
+	if *actionsFile != "" {
+		var err error
+		if a, err = loadActions(*actionsFile); err != nil {
+			log.Fatalf("Invalid actions file %v: %v", *actionsFile, err)
+		}
+	}
+
+	if *bind || *recording != "" {
+		if a == nil {
+			log.Fatal("-bind and -evdev_recording require -actions")
+		}
+
+		if *bind {
+			bindActions(a)
+			return
+		}
+
+		// Light actions need the mouse, but the others can be tried
+		// without it.
+		dev, err := device.Open()
+		if err != nil {
+			log.Printf("Error opening the device: %v", err)
+			dev = nil
+		}
+
+		followKeys(context.Background(), a, dev, *recording)
+		return
+	}
+
+	events, err := device.Watch(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var dev *device.Device
+	var following sync.WaitGroup
+	stopEvents := func() {}
+
+	// disconnect stops following the events of the device, and waits
+	// for that before closing it.
+	disconnect := func() {
+		stopEvents()
+		following.Wait()
+		if m != nil {
+			m.deviceDisconnected()
+		}
+		if dev != nil {
+			dev.Close()
+			dev = nil
+		}
+	}
+
+	initial := true
+	for ev := range events {
+		// Watch starts by telling whether the mouse is connected; that
+		// it is not is no change, so it runs no hooks.
+		if initial && ev == device.DeviceDisconnected {
+			initial = false
+			log.Print("Mouse not connected")
+			continue
+		}
+		initial = false
+
+		log.Printf("Mouse %v", ev)
+
+		if h != nil {
+			h.run(newHotplugHookEvent(ev))
+		}
+
+		switch ev {
+		case device.DeviceConnected:
+			// Connected again without being disconnected first, e.g.
+			// on duplicate uevents: start over.
+			if dev != nil {
+				disconnect()
+			}
+
+			dev, err = device.Open()
+			if err != nil {
+				log.Printf("Error opening the device: %v", err)
+				dev = nil
+				continue
+			}
+
+			if m != nil {
+				m.deviceConnected(dev)
+			}
+
+			var ctx context.Context
+			ctx, stopEvents = context.WithCancel(context.Background())
+			if h != nil || st != nil || m != nil {
+				following.Add(1)
+				go func(dev *device.Device) {
+					defer following.Done()
+					followEvents(ctx, dev, h, st, m)
+				}(dev)
+			}
+			if a != nil {
+				following.Add(1)
+				go func(dev *device.Device) {
+					defer following.Done()
+					followKeys(ctx, a, dev, "")
+				}(dev)
+			}
+
+			if *stateFile == "" {
+				continue
+			}
+
+			state, err := device.LoadState(*stateFile)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+
+			state = restoreState(dev, state)
+			if err := dev.Apply(state); err != nil {
+				log.Printf("Error applying %v: %v", *stateFile, err)
+				continue
+			}
+
+			if state.Config != nil {
+				if err := device.RecordConfig(state.Config); err != nil {
+					log.Printf("Configuration written, but not recorded: %v", err)
+				}
+			}
+
+		case device.DeviceDisconnected:
+			disconnect()
+		}
+	}
+}
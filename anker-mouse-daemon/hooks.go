@@ -0,0 +1,273 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookTimeout       = 10 * time.Second
+	defaultHookMaxConcurrent = 4
+)
+
+// hook runs either a shell command or an HTTP POST for the listed
+// events.
+type hook struct {
+	Events  []string `json:"events"`
+	Command string   `json:"command"`
+	URL     string   `json:"url"`
+}
+
+// hooks is the format of the -hooks file, e.g.:
+//
+//	{
+//	  "timeout": "5s",
+//	  "max_concurrent": 2,
+//	  "hooks": [
+//	    {"events": ["connected", "disconnected"], "command": "logger -t anker-mouse $ANKER_MOUSE_EVENT"},
+//	    {"events": ["profile", "dpi_stage"], "url": "http://localhost:8080/mouse"}
+//	  ]
+//	}
+//
+// Hooks still running after the timeout are killed. When as many hooks
+// as max_concurrent are running, further events are dropped rather
+// than queued.
+type hooks struct {
+	Timeout       string  `json:"timeout"`
+	MaxConcurrent int     `json:"max_concurrent"`
+	Hooks         []*hook `json:"hooks"`
+
+	timeout time.Duration
+	running chan struct{}
+	// The hooks started and not finished yet.
+	wg sync.WaitGroup
+}
+
+// hookEvents are the names of the events hooks can be run for.
+var hookEvents = map[string]bool{
+	device.DeviceConnected.String():    true,
+	device.DeviceDisconnected.String(): true,
+	device.ProfileChanged.String():     true,
+	device.DPIStageChanged.String():    true,
+	device.ButtonEvent.String():        true,
+	device.KeyEvent.String():           true,
+}
+
+func loadHooks(path string) (*hooks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hooks{
+		timeout:       defaultHookTimeout,
+		MaxConcurrent: defaultHookMaxConcurrent,
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+
+	if h.Timeout != "" {
+		if h.timeout, err = time.ParseDuration(h.Timeout); err != nil {
+			return nil, fmt.Errorf("Invalid timeout: %v", err)
+		}
+	}
+
+	if h.MaxConcurrent < 1 {
+		return nil, fmt.Errorf("Invalid max_concurrent: %v", h.MaxConcurrent)
+	}
+	h.running = make(chan struct{}, h.MaxConcurrent)
+
+	for i, hk := range h.Hooks {
+		if (hk.Command == "") == (hk.URL == "") {
+			return nil, fmt.Errorf("Hook %v needs exactly one of command or url", i)
+		}
+
+		if len(hk.Events) == 0 {
+			return nil, fmt.Errorf("Hook %v has no events", i)
+		}
+		for _, ev := range hk.Events {
+			if !hookEvents[ev] {
+				return nil, fmt.Errorf("Unknown event %q for hook %v", ev, i)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// hookEvent is the data passed to the hooks: as JSON in the body of
+// the HTTP requests, and as ANKER_MOUSE_* environment variables to the
+// commands.
+type hookEvent struct {
+	Event    string           `json:"event"`
+	Time     time.Time        `json:"time"`
+	Profile  device.ProfileID `json:"profile,omitempty"`
+	DPIStage device.DPIStage  `json:"dpi_stage,omitempty"`
+	Button   int              `json:"button,omitempty"`
+	Key      uint32           `json:"key,omitempty"`
+	Pressed  *bool            `json:"pressed,omitempty"`
+}
+
+func newHotplugHookEvent(ev device.HotplugEvent) *hookEvent {
+	return &hookEvent{
+		Event: ev.String(),
+		Time:  time.Now(),
+	}
+}
+
+func newDeviceHookEvent(ev device.Event) *hookEvent {
+	he := &hookEvent{
+		Event:    ev.Kind.String(),
+		Time:     ev.Time,
+		Profile:  ev.Profile,
+		DPIStage: ev.DPIStage,
+		Button:   ev.Button,
+	}
+
+	if ev.Kind == device.KeyEvent {
+		he.Key = ev.Usage
+	}
+
+	if ev.Kind == device.ButtonEvent || ev.Kind == device.KeyEvent {
+		pressed := ev.Pressed
+		he.Pressed = &pressed
+	}
+
+	return he
+}
+
+func (self *hookEvent) environ() []string {
+	env := []string{
+		"ANKER_MOUSE_EVENT=" + self.Event,
+		"ANKER_MOUSE_TIME=" + self.Time.Format(time.RFC3339Nano),
+	}
+
+	if self.Profile != 0 {
+		env = append(env, "ANKER_MOUSE_PROFILE="+strconv.Itoa(int(self.Profile)))
+	}
+	if self.DPIStage != 0 {
+		env = append(env, "ANKER_MOUSE_DPI_STAGE="+strconv.Itoa(int(self.DPIStage)))
+	}
+	if self.Button != 0 {
+		env = append(env, "ANKER_MOUSE_BUTTON="+strconv.Itoa(self.Button))
+	}
+	if self.Key != 0 {
+		env = append(env, fmt.Sprintf("ANKER_MOUSE_KEY=%#08x", self.Key))
+	}
+	if self.Pressed != nil {
+		env = append(env, "ANKER_MOUSE_PRESSED="+strconv.FormatBool(*self.Pressed))
+	}
+
+	return env
+}
+
+func (self *hook) wants(event string) bool {
+	for _, ev := range self.Events {
+		if ev == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *hook) run(ctx context.Context, ev *hookEvent) error {
+	if self.Command != "" {
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", self.Command)
+		cmd.Env = append(os.Environ(), ev.environ()...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		return cmd.Run()
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%v answered %v", self.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// run starts, in the background, the hooks that want the event.
+func (self *hooks) run(ev *hookEvent) {
+	for i, hk := range self.Hooks {
+		if !hk.wants(ev.Event) {
+			continue
+		}
+
+		select {
+		case self.running <- struct{}{}:
+		default:
+			log.Printf("Too many hooks running (%v), dropping %v event of %v for hook %v", cap(self.running), ev.Event, ev.Time.Format(time.RFC3339Nano), i)
+			continue
+		}
+
+		self.wg.Add(1)
+		go func(i int, hk *hook) {
+			defer self.wg.Done()
+			defer func() { <-self.running }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), self.timeout)
+			defer cancel()
+
+			if err := hk.run(ctx, ev); err != nil {
+				log.Printf("Error running hook %v for %v: %v", i, ev.Event, err)
+			}
+		}(i, hk)
+	}
+}
+
+// wait waits for the hooks started to finish.
+func (self *hooks) wait() {
+	self.wg.Wait()
+}
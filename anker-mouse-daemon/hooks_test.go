@@ -0,0 +1,200 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHooks loads the hooks from config, with HOOK_DIR set to a
+// temporary directory for the commands to write to.
+func newTestHooks(t *testing.T, config string) (*hooks, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOOK_DIR", dir)
+
+	path := filepath.Join(dir, "hooks.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := loadHooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.wait)
+
+	return h, dir
+}
+
+// captureLog returns the buffer the log is written to until the end of
+// the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	return buf
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestHooksDispatch(t *testing.T) {
+	var posted []hookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hookEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("Invalid body posted: %v", err)
+		}
+		posted = append(posted, ev)
+	}))
+	defer server.Close()
+
+	h, dir := newTestHooks(t, `{"hooks": [
+		{"events": ["button", "profile"], "command": "echo $ANKER_MOUSE_EVENT,$ANKER_MOUSE_BUTTON,$ANKER_MOUSE_PRESSED,$ANKER_MOUSE_PROFILE >> $HOOK_DIR/out"},
+		{"events": ["connected"], "url": "`+server.URL+`"}
+	]}`)
+
+	now := time.Now()
+	events := []*hookEvent{
+		newDeviceHookEvent(device.Event{Kind: device.ButtonEvent, Time: now, Button: 4, Usage: 0x90004, Pressed: true}),
+		newHotplugHookEvent(device.DeviceConnected),
+		newDeviceHookEvent(device.Event{Kind: device.DPIStageChanged, Time: now, DPIStage: 3}),
+		newDeviceHookEvent(device.Event{Kind: device.ProfileChanged, Time: now, Profile: device.Profile2}),
+	}
+	// Waiting for each event keeps the order of the output.
+	for _, ev := range events {
+		h.run(ev)
+		h.wait()
+	}
+
+	want := []string{"button,4,true,", "profile,,,2"}
+	if got := readLines(t, filepath.Join(dir, "out")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands ran for %q, want %q", got, want)
+	}
+
+	if len(posted) != 1 || posted[0].Event != "connected" {
+		t.Errorf("Posted %+v, want one connected event", posted)
+	}
+}
+
+func TestHooksConcurrencyLimit(t *testing.T) {
+	logged := captureLog(t)
+
+	// The hook runs until HOOK_DIR/release exists.
+	h, dir := newTestHooks(t, `{"max_concurrent": 1, "hooks": [
+		{"events": ["connected", "disconnected"], "command": "while [ ! -e $HOOK_DIR/release ]; do sleep 0.01; done; echo $ANKER_MOUSE_EVENT >> $HOOK_DIR/out"}
+	]}`)
+
+	h.run(newHotplugHookEvent(device.DeviceConnected))
+	h.run(newHotplugHookEvent(device.DeviceDisconnected))
+
+	if err := os.WriteFile(filepath.Join(dir, "release"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.wait()
+
+	if got, want := readLines(t, filepath.Join(dir, "out")), []string{"connected"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands ran for %q, want %q", got, want)
+	}
+	if !strings.Contains(logged.String(), "dropping disconnected event") {
+		t.Errorf("Dropped event not logged:\n%v", logged)
+	}
+
+	// Once the hook is done, the next event runs it again.
+	h.run(newHotplugHookEvent(device.DeviceDisconnected))
+	h.wait()
+
+	if got, want := readLines(t, filepath.Join(dir, "out")), []string{"connected", "disconnected"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands ran for %q, want %q", got, want)
+	}
+}
+
+func TestHooksTimeout(t *testing.T) {
+	logged := captureLog(t)
+
+	h, _ := newTestHooks(t, `{"timeout": "100ms", "hooks": [
+		{"events": ["connected"], "command": "exec sleep 10"}
+	]}`)
+
+	start := time.Now()
+	h.run(newHotplugHookEvent(device.DeviceConnected))
+	h.wait()
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Hook ran for %v despite the timeout", d)
+	}
+	if !strings.Contains(logged.String(), "Error running hook 0 for connected") {
+		t.Errorf("Killed hook not logged:\n%v", logged)
+	}
+}
+
+func TestLoadHooksErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"command and url", `{"hooks": [{"events": ["connected"], "command": "true", "url": "http://localhost/"}]}`},
+		{"neither command nor url", `{"hooks": [{"events": ["connected"]}]}`},
+		{"no events", `{"hooks": [{"command": "true"}]}`},
+		{"unknown event", `{"hooks": [{"events": ["motion"], "command": "true"}]}`},
+		{"invalid timeout", `{"timeout": "soon", "hooks": []}`},
+		{"invalid max_concurrent", `{"max_concurrent": 0, "hooks": []}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hooks.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := loadHooks(path); err == nil {
+				t.Error("loadHooks succeeded")
+			}
+		})
+	}
+}
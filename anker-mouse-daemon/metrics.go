@@ -0,0 +1,188 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// metrics exposes the health and usage of the mouse to Prometheus.
+type metrics struct {
+	reports   *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	reconnect prometheus.Counter
+	connected prometheus.Gauge
+	profile   prometheus.Gauge
+	stage     prometheus.Gauge
+	light     *prometheus.GaugeVec
+
+	// Whether the mouse was connected before, to tell reconnections
+	// from the first connection.
+	seen bool
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		reports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anker_mouse_feature_reports_total",
+			Help: "Feature reports exchanged with the mouse, by type (e.g. light, set_profile, buttons_profile) and direction.",
+		}, []string{"type", "direction"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anker_mouse_report_errors_total",
+			Help: "Feature reports that could not be sent or received, by cause.",
+		}, []string{"cause"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "anker_mouse_report_duration_seconds",
+			Help:    "Time taken by the mouse to accept or return a feature report.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 12),
+		}, []string{"direction"}),
+		reconnect: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "anker_mouse_reconnects_total",
+			Help: "Times the mouse was connected again after being disconnected.",
+		}),
+		connected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "anker_mouse_connected",
+			Help: "Whether the mouse is connected.",
+		}),
+		profile: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "anker_mouse_active_profile",
+			Help: "Active profile of the mouse (1 or 2), or 0 if not known (it is only read with ANKER_MOUSE_EXPERIMENTAL set).",
+		}),
+		stage: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "anker_mouse_dpi_stage",
+			Help: "Active DPI stage of the mouse (1 to 4), or 0 if not known (it is only read with ANKER_MOUSE_EXPERIMENTAL set).",
+		}),
+		light: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "anker_mouse_light",
+			Help: "Last light set by the daemon itself, by channel: red, green and blue (0-255), brightness and breath_speed (0-3). Lights set by other tools, or by the profile, are not seen.",
+		}, []string{"channel"}),
+	}
+
+	reg.MustRegister(m.reports, m.errors, m.latency, m.reconnect, m.connected, m.profile, m.stage, m.light)
+
+	return m
+}
+
+// serveMetrics serves the metrics on /metrics at addr, in the
+// background.
+func serveMetrics(addr string) *metrics {
+	reg := prometheus.NewRegistry()
+	m := newMetrics(reg)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+
+	return m
+}
+
+// errorCause labels the errors of the transport, e.g. no_such_device
+// once the mouse is unplugged.
+func errorCause(err error) string {
+	var errno syscall.Errno
+
+	switch {
+	case errors.Is(err, device.ErrInvalidReport):
+		return "invalid_report"
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &errno):
+		return strings.ReplaceAll(strings.ToLower(errno.Error()), " ", "_")
+	}
+
+	return "other"
+}
+
+func (self *metrics) observe(info *device.ReportInfo) {
+	if info.Err != nil {
+		self.errors.WithLabelValues(errorCause(info.Err)).Inc()
+		return
+	}
+
+	self.reports.WithLabelValues(info.Type, info.Direction.String()).Inc()
+	self.latency.WithLabelValues(info.Direction.String()).Observe(info.Duration.Seconds())
+
+	// Follow the changes made by the daemon through its own handle
+	// of the device: the other tools run in their own processes, and
+	// are not seen. The changes made with the buttons come as events.
+	switch {
+	case len(info.Data) <= 8:
+	case info.Type == "set_profile":
+		self.profile.Set(float64(info.Data[8] + 1))
+	case info.Type == "set_dpi_stage":
+		self.stage.Set(float64(info.Data[8] + 1))
+	}
+
+	if r, ok := info.Report.(*device.SetLightReport); ok {
+		self.light.WithLabelValues("red").Set(float64(^r.InverseRed))
+		self.light.WithLabelValues("green").Set(float64(^r.InverseGreen))
+		self.light.WithLabelValues("blue").Set(float64(^r.InverseBlue))
+		self.light.WithLabelValues("brightness").Set(float64(r.Brightness))
+		self.light.WithLabelValues("breath_speed").Set(float64(r.BreathSpeed))
+	}
+}
+
+// deviceConnected starts measuring a newly connected mouse.
+func (self *metrics) deviceConnected(dev *device.Device) {
+	if self.seen {
+		self.reconnect.Inc()
+	}
+	self.seen = true
+	self.connected.Set(1)
+
+	dev.Observe(self.observe)
+
+	if p, err := dev.ActiveProfile(); err == nil {
+		self.profile.Set(float64(p))
+	}
+	if s, err := dev.ActiveDPIStage(); err == nil {
+		self.stage.Set(float64(s))
+	}
+}
+
+func (self *metrics) deviceDisconnected() {
+	self.connected.Set(0)
+	self.profile.Set(0)
+	self.stage.Set(0)
+}
+
+func (self *metrics) add(ev device.Event) {
+	switch ev.Kind {
+	case device.ProfileChanged:
+		self.profile.Set(float64(ev.Profile))
+	case device.DPIStageChanged:
+		self.stage.Set(float64(ev.DPIStage))
+	}
+}
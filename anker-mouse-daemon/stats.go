@@ -0,0 +1,273 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const statsSaveInterval = time.Minute
+
+type wheelStats struct {
+	Up    int `json:"up"`
+	Down  int `json:"down"`
+	Left  int `json:"left"`
+	Right int `json:"right"`
+}
+
+type distanceStats struct {
+	Counts float64 `json:"counts"`
+	Metres float64 `json:"metres"`
+}
+
+// usageStats is the format of the -stats file. Profiles and buttons
+// are indexed from zero.
+type usageStats struct {
+	Since time.Time `json:"since"`
+
+	// Presses by HID usage name, e.g. Button1 or LeftAlt.
+	Presses map[string]int `json:"presses"`
+	// Presses of the buttons of each profile, as told by what they
+	// are bound to in the last configuration written by the tools.
+	ButtonPresses [2][9]int `json:"button_presses"`
+
+	Wheel wheelStats `json:"wheel"`
+
+	// Distance travelled at each DPI stage of each profile.
+	Distance [2][4]distanceStats `json:"distance"`
+
+	ProfileSeconds [2]float64 `json:"profile_seconds"`
+
+	// Time the mouse was connected without a known profile, when the
+	// button presses and the time per profile are not counted, or
+	// without a known DPI stage, when the distance is not counted.
+	// Both are only read from the mouse with the experimental features.
+	Uncounted uncountedStats `json:"uncounted"`
+}
+
+type uncountedStats struct {
+	ProfileSeconds  float64 `json:"profile_seconds"`
+	DPIStageSeconds float64 `json:"dpi_stage_seconds"`
+}
+
+func loadStats(path string) (*usageStats, error) {
+	s := &usageStats{
+		Since:   time.Now(),
+		Presses: make(map[string]int),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Presses == nil {
+		s.Presses = make(map[string]int)
+	}
+
+	return s, nil
+}
+
+// statsCollector counts the usage of the mouse from its events. The
+// statistics never leave the computer: they are only saved to a local
+// file.
+type statsCollector struct {
+	mu    sync.Mutex
+	path  string
+	stats *usageStats
+	cfg   *device.Config
+
+	// The profile assumed when the active one cannot be read.
+	fallbackProfile device.ProfileID
+
+	profile device.ProfileID
+	stage   device.DPIStage
+	since   time.Time // Since when the profile is active; zero while disconnected.
+}
+
+func newStatsCollector(path string, fallbackProfile device.ProfileID) (*statsCollector, error) {
+	s, err := loadStats(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := lastConfigOrDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsCollector{
+		path:            path,
+		stats:           s,
+		cfg:             cfg,
+		fallbackProfile: fallbackProfile,
+	}, nil
+}
+
+// connected starts counting for a newly connected mouse. Without the
+// experimental features, the active profile is assumed to be the
+// fallback one, and the DPI stage is not known, so the distance is not
+// counted.
+func (self *statsCollector) connected(dev *device.Device) {
+	profile, err := dev.ActiveProfile()
+	if err != nil {
+		profile = self.fallbackProfile
+		if profile.Valid() {
+			log.Printf("Unable to read the active profile, counting statistics for profile %v: %v", profile, err)
+		} else {
+			log.Printf("Unable to read the active profile, no button presses or time per profile are counted: %v", err)
+		}
+	}
+
+	stage, err := dev.ActiveDPIStage()
+	if err != nil {
+		log.Printf("Unable to read the active DPI stage, the distance is not counted: %v", err)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.profile, self.stage, self.since = profile, stage, time.Now()
+}
+
+// flushTime adds the time spent in the current profile, or without a
+// known profile or DPI stage, while connected.
+func (self *statsCollector) flushTime(now time.Time) {
+	if self.since.IsZero() {
+		return
+	}
+
+	secs := now.Sub(self.since).Seconds()
+	if self.profile.Valid() {
+		self.stats.ProfileSeconds[self.profile-1] += secs
+	} else {
+		self.stats.Uncounted.ProfileSeconds += secs
+	}
+	if !self.stage.Valid() {
+		self.stats.Uncounted.DPIStageSeconds += secs
+	}
+
+	self.since = now
+}
+
+func (self *statsCollector) disconnected() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.flushTime(time.Now())
+	self.profile, self.stage, self.since = 0, 0, time.Time{}
+}
+
+func (self *statsCollector) add(ev device.Event) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	switch ev.Kind {
+	case device.MotionEvent:
+		if !self.profile.Valid() || !self.stage.Valid() {
+			return
+		}
+
+		d := &self.stats.Distance[self.profile-1][self.stage-1]
+		d.Counts += math.Hypot(float64(ev.X), float64(ev.Y))
+
+		dpi := self.cfg.Profiles[self.profile-1].DPIValues()[self.stage-1]
+		if dpi[0] != 0 {
+			inches := math.Hypot(float64(ev.X)/float64(dpi[0]), float64(ev.Y)/float64(dpi[1]))
+			d.Metres += inches * 0.0254
+		}
+
+	case device.WheelEvent:
+		switch {
+		case ev.Y > 0:
+			self.stats.Wheel.Up += ev.Y
+		case ev.Y < 0:
+			self.stats.Wheel.Down -= ev.Y
+		}
+		switch {
+		case ev.X > 0:
+			self.stats.Wheel.Right += ev.X
+		case ev.X < 0:
+			self.stats.Wheel.Left -= ev.X
+		}
+
+	case device.ButtonEvent, device.KeyEvent:
+		if !ev.Pressed {
+			return
+		}
+
+		self.stats.Presses[device.UsageName(ev.Usage)]++
+
+		if !self.profile.Valid() {
+			return
+		}
+		for i, b := range self.cfg.Profiles[self.profile-1].Buttons {
+			if b.Produces(ev) {
+				self.stats.ButtonPresses[self.profile-1][i]++
+				break
+			}
+		}
+
+	case device.ProfileChanged:
+		self.flushTime(ev.Time)
+		self.profile = ev.Profile
+
+	case device.DPIStageChanged:
+		self.stage = ev.DPIStage
+	}
+}
+
+// save writes the statistics to the file, through a temporary file so
+// that they are not lost if interrupted.
+func (self *statsCollector) save() error {
+	self.mu.Lock()
+	self.flushTime(time.Now())
+	data, err := json.MarshalIndent(self.stats, "", "  ")
+	self.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(self.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := self.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, self.path)
+}
@@ -0,0 +1,273 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/device/devicetest"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCollector returns a collector saving to a temporary file, with
+// no configuration recorded by the tools.
+func newTestCollector(t *testing.T, fallbackProfile device.ProfileID) *statsCollector {
+	t.Helper()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	st, err := newStatsCollector(filepath.Join(t.TempDir(), "stats.json"), fallbackProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return st
+}
+
+var leftPress = device.Event{Kind: device.ButtonEvent, Button: 1, Usage: 0x90001, Pressed: true}
+
+func TestStatsFallbackProfile(t *testing.T) {
+	st := newTestCollector(t, device.Profile2)
+
+	// Without the experimental features, nothing is read from the
+	// mouse.
+	st.connected(devicetest.OpenCassette(t, "testdata/no-reports.jsonl"))
+	if st.profile != device.Profile2 || st.stage != 0 {
+		t.Fatalf("Connected in profile %v, DPI stage %v; want profile 2 and no stage", st.profile, st.stage)
+	}
+
+	st.add(leftPress)
+	st.add(device.Event{Kind: device.MotionEvent, X: 30, Y: 40})
+
+	now := time.Now()
+	st.since = now.Add(-10 * time.Second)
+	st.flushTime(now)
+
+	if n := st.stats.ButtonPresses[1][0]; n != 1 {
+		t.Errorf("%v presses of button 1 of profile 2, want 1", n)
+	}
+	if st.stats.Distance != ([2][4]distanceStats{}) {
+		t.Errorf("Distance counted without a DPI stage: %v", st.stats.Distance)
+	}
+	want := usageStats{
+		ProfileSeconds: [2]float64{0, 10},
+		Uncounted:      uncountedStats{DPIStageSeconds: 10},
+	}
+	if st.stats.ProfileSeconds != want.ProfileSeconds || st.stats.Uncounted != want.Uncounted {
+		t.Errorf("Time %v, uncounted %+v; want %v, %+v", st.stats.ProfileSeconds, st.stats.Uncounted, want.ProfileSeconds, want.Uncounted)
+	}
+}
+
+func TestStatsNoProfile(t *testing.T) {
+	st := newTestCollector(t, 0)
+	st.connected(devicetest.OpenCassette(t, "testdata/no-reports.jsonl"))
+
+	st.add(leftPress)
+	now := time.Now()
+	st.since = now.Add(-5 * time.Second)
+	st.disconnected()
+
+	if st.stats.Presses["Button1"] != 1 {
+		t.Errorf("Presses %v, want one of Button1", st.stats.Presses)
+	}
+	if st.stats.ButtonPresses != ([2][9]int{}) {
+		t.Errorf("Button presses counted without a profile: %v", st.stats.ButtonPresses)
+	}
+	if secs := st.stats.Uncounted.ProfileSeconds; secs < 5 {
+		t.Errorf("%v seconds without a profile, want at least 5", secs)
+	}
+
+	// Nothing is counted while disconnected.
+	before := st.stats.Uncounted
+	st.flushTime(now.Add(time.Hour))
+	if st.stats.Uncounted != before {
+		t.Errorf("Time counted while disconnected: %+v, was %+v", st.stats.Uncounted, before)
+	}
+}
+
+func TestStatsAdd(t *testing.T) {
+	st := newTestCollector(t, 0)
+	st.profile, st.stage, st.since = device.Profile1, 2, time.Now()
+
+	st.add(device.Event{Kind: device.MotionEvent, X: 300, Y: 400})
+	st.add(device.Event{Kind: device.WheelEvent, Y: 2})
+	st.add(device.Event{Kind: device.WheelEvent, Y: -1, X: 3})
+	st.add(leftPress)
+	st.add(device.Event{Kind: device.ButtonEvent, Button: 1, Usage: 0x90001})
+	st.add(device.Event{Kind: device.ProfileChanged, Profile: device.Profile2, Time: time.Now()})
+	st.add(leftPress)
+
+	d := st.stats.Distance[0][1]
+	dpi := st.cfg.Profiles[0].DPIValues()[1]
+	wantMetres := math.Hypot(300/float64(dpi[0]), 400/float64(dpi[1])) * 0.0254
+	if d.Counts != 500 || math.Abs(d.Metres-wantMetres) > 1e-9 {
+		t.Errorf("Distance at stage 2 of profile 1: %+v, want 500 counts and %v metres", d, wantMetres)
+	}
+	if want := (wheelStats{Up: 2, Down: 1, Right: 3}); st.stats.Wheel != want {
+		t.Errorf("Wheel %+v, want %+v", st.stats.Wheel, want)
+	}
+	if st.stats.ButtonPresses[0][0] != 1 || st.stats.ButtonPresses[1][0] != 1 || st.stats.Presses["Button1"] != 2 {
+		t.Errorf("Presses %v, button presses %v; want one of button 1 in each profile", st.stats.Presses, st.stats.ButtonPresses)
+	}
+}
+
+func TestStatsSaveLoad(t *testing.T) {
+	st := newTestCollector(t, 0)
+	st.stats.Presses["F13"] = 4
+	st.stats.ButtonPresses[1][8] = 2
+	st.stats.Wheel.Left = 7
+	st.stats.Distance[0][3] = distanceStats{Counts: 1500, Metres: 0.25}
+	st.stats.ProfileSeconds = [2]float64{60, 30}
+	st.stats.Uncounted.DPIStageSeconds = 90
+
+	if err := st.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadStats(st.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Since.Equal(st.stats.Since) {
+		t.Errorf("Loaded since %v, saved %v", loaded.Since, st.stats.Since)
+	}
+	loaded.Since = st.stats.Since
+	if !reflect.DeepEqual(loaded, st.stats) {
+		t.Errorf("Loaded %+v, saved %+v", loaded, st.stats)
+	}
+}
+
+func TestLoadStatsMissing(t *testing.T) {
+	s, err := loadStats(filepath.Join(t.TempDir(), "stats.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Presses == nil || s.Since.IsZero() {
+		t.Errorf("New statistics %+v, want presses and a start time", s)
+	}
+}
+
+func testStats() *usageStats {
+	s := &usageStats{
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Presses: map[string]int{"Button1": 10, "F13": 2},
+	}
+	s.ButtonPresses[0][0] = 10
+	s.ButtonPresses[1][6] = 2
+	s.Wheel = wheelStats{Up: 5, Down: 3}
+	s.Distance[0][1] = distanceStats{Counts: 2000, Metres: 0.0254}
+	s.ProfileSeconds = [2]float64{3600, 0}
+	s.Uncounted = uncountedStats{ProfileSeconds: 120, DPIStageSeconds: 120.5}
+
+	return s
+}
+
+func TestExportStatsCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := exportStats(&b, testStats(), "csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	// Header, presses, button presses, wheel, distance, time and
+	// uncounted time.
+	if want := 1 + 2 + 18 + 4 + 16 + 2 + 2; len(lines) != want {
+		t.Errorf("%v lines, want %v", len(lines), want)
+	}
+
+	for _, want := range []string{
+		"metric,profile,item,value",
+		"presses,,Button1,10",
+		"presses,,F13,2",
+		"button_presses,1,1,10",
+		"button_presses,2,7,2",
+		"wheel,,up,5",
+		"wheel,,down,3",
+		"distance_counts,1,2,2000",
+		"distance_metres,1,2,0.0254",
+		"profile_seconds,1,,3600",
+		"uncounted_seconds,,profile,120",
+		"uncounted_seconds,,dpi_stage,120.5",
+	} {
+		found := false
+		for _, l := range lines {
+			if l == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("No line %q in:\n%v", want, b.String())
+		}
+	}
+}
+
+func TestExportStatsJSON(t *testing.T) {
+	var b bytes.Buffer
+	s := testStats()
+	if err := exportStats(&b, s, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(usageStats)
+	if err := json.Unmarshal(b.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("Exported %+v, want %+v", got, s)
+	}
+}
+
+func TestExportStatsSVG(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var b bytes.Buffer
+	if err := exportStats(&b, testStats(), "svg"); err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+
+	if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Errorf("Not an SVG document:\n%v", svg)
+	}
+	if n := strings.Count(svg, "<rect "); n != 18 {
+		t.Errorf("%v cells, want 18", n)
+	}
+	// The most used button is the hottest.
+	if !strings.Contains(svg, `fill="#cc0000"`) {
+		t.Errorf("No cell at the hottest color:\n%v", svg)
+	}
+	if !strings.Contains(svg, "Presses not counted for 2m0s without a known profile") {
+		t.Errorf("No note about the uncounted presses:\n%v", svg)
+	}
+}
+
+func TestExportStatsUnknownFormat(t *testing.T) {
+	if err := exportStats(&bytes.Buffer{}, testStats(), "xml"); err == nil {
+		t.Error("Exported in an unknown format")
+	}
+}
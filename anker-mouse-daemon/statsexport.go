@@ -0,0 +1,177 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exportStats writes the statistics as csv, json or svg.
+func exportStats(w io.Writer, s *usageStats, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+
+	case "csv":
+		return exportStatsCSV(w, s)
+
+	case "svg":
+		cfg, err := lastConfigOrDefault()
+		if err != nil {
+			return err
+		}
+		return exportHeatmap(w, s, cfg)
+	}
+
+	return fmt.Errorf("Unknown statistics format %q: must be csv, json or svg", format)
+}
+
+// exportStatsCSV writes one row for each value, as metric, profile,
+// item and value; profiles, buttons and DPI stages are numbered from
+// one.
+func exportStatsCSV(w io.Writer, s *usageStats) error {
+	cw := csv.NewWriter(w)
+
+	row := func(metric string, profile int, item string, value float64) {
+		p := ""
+		if profile > 0 {
+			p = strconv.Itoa(profile)
+		}
+		cw.Write([]string{metric, p, item, strconv.FormatFloat(value, 'f', -1, 64)})
+	}
+
+	cw.Write([]string{"metric", "profile", "item", "value"})
+
+	var names []string
+	for name := range s.Presses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row("presses", 0, name, float64(s.Presses[name]))
+	}
+
+	for p := range s.ButtonPresses {
+		for b, n := range s.ButtonPresses[p] {
+			row("button_presses", p+1, strconv.Itoa(b+1), float64(n))
+		}
+	}
+
+	row("wheel", 0, "up", float64(s.Wheel.Up))
+	row("wheel", 0, "down", float64(s.Wheel.Down))
+	row("wheel", 0, "left", float64(s.Wheel.Left))
+	row("wheel", 0, "right", float64(s.Wheel.Right))
+
+	for p := range s.Distance {
+		for stage, d := range s.Distance[p] {
+			row("distance_counts", p+1, strconv.Itoa(stage+1), d.Counts)
+			row("distance_metres", p+1, strconv.Itoa(stage+1), d.Metres)
+		}
+	}
+
+	for p, secs := range s.ProfileSeconds {
+		row("profile_seconds", p+1, "", secs)
+	}
+
+	row("uncounted_seconds", 0, "profile", s.Uncounted.ProfileSeconds)
+	row("uncounted_seconds", 0, "dpi_stage", s.Uncounted.DPIStageSeconds)
+
+	cw.Flush()
+	return cw.Error()
+}
+
+const (
+	heatmapCellWidth  = 110
+	heatmapCellHeight = 60
+	heatmapLabelWidth = 80
+)
+
+// exportHeatmap draws the presses of each button of each profile, from
+// white (unused) to red (most used), labelled with what they are bound
+// to.
+func exportHeatmap(w io.Writer, s *usageStats, cfg *device.Config) error {
+	max := 0
+	for p := range s.ButtonPresses {
+		for _, n := range s.ButtonPresses[p] {
+			if n > max {
+				max = n
+			}
+		}
+	}
+
+	cols := len(s.ButtonPresses[0])
+	width := heatmapLabelWidth + cols*heatmapCellWidth
+	height := (len(s.ButtonPresses) + 2) * heatmapCellHeight
+
+	cold := colorful.Color{R: 1, G: 1, B: 1}
+	hot := colorful.Color{R: 0.8, G: 0, B: 0}
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+
+	for b := 0; b < cols; b++ {
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">button %d</text>`+"\n",
+			heatmapLabelWidth+b*heatmapCellWidth+heatmapCellWidth/2, heatmapCellHeight/2, b+1)
+	}
+
+	for p := range s.ButtonPresses {
+		y := (p + 1) * heatmapCellHeight
+		fmt.Fprintf(w, `<text x="0" y="%d">profile %d</text>`+"\n", y+heatmapCellHeight/2, p+1)
+
+		for b, n := range s.ButtonPresses[p] {
+			t := 0.0
+			if max > 0 {
+				t = float64(n) / float64(max)
+			}
+			x := heatmapLabelWidth + b*heatmapCellWidth
+
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%v" stroke="#999"/>`+"\n",
+				x, y, heatmapCellWidth, heatmapCellHeight, cold.BlendLab(hot, t).Clamped().Hex())
+			fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">%v</text>`+"\n",
+				x+heatmapCellWidth/2, y+heatmapCellHeight/2-4, html.EscapeString(cfg.Profiles[p].Buttons[b].String()))
+			fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">%d</text>`+"\n",
+				x+heatmapCellWidth/2, y+heatmapCellHeight/2+12, n)
+		}
+	}
+
+	if s.Uncounted.ProfileSeconds > 0 {
+		fmt.Fprintf(w, `<text x="0" y="%d">Presses not counted for %v without a known profile (see %v).</text>`+"\n",
+			height-heatmapCellHeight/2, time.Duration(s.Uncounted.ProfileSeconds)*time.Second, device.ExperimentalEnv)
+	}
+
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
@@ -0,0 +1,248 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/evdev"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+	threshold       = flag.Duration("threshold", 40*time.Millisecond, "Ignore presses of a button coming sooner than this after it was released, together with the release; releases are delayed by this much.")
+	buttonThreshold = flag.String("button_threshold", "", "Thresholds for single buttons, overriding -threshold, e.g. left=60ms,side=0 (0 disables the filter).")
+	statsInterval   = flag.Duration("stats_interval", 0, "Log the statistics of suppressed presses this often, besides on exit.")
+	recording       = flag.String("recording", "", "Filter this recording of an evdev node rather than the mouse, then exit.")
+	output          = flag.String("output", "", "With -recording, write the filtered events to this file as a recording.")
+)
+
+const uinputName = "Anker mouse (debounced)"
+
+// findButtonsNode returns the event node of the mouse that reports the
+// buttons, as opposed to the keys.
+func findButtonsNode() (string, map[uint16][]uint16, error) {
+	paths, err := evdev.Find(device.HoltekVendorId, device.AnkerMouseDeviceId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, path := range paths {
+		caps, err := evdev.Capabilities(path)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if evdev.HasCode(caps, evdev.EvKey, evdev.BtnLeft) {
+			return path, caps, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("No event node of %04x:%04x reports mouse buttons", device.HoltekVendorId, device.AnkerMouseDeviceId)
+}
+
+// debounce grabs the event node of the mouse, and re-emits its events,
+// filtered, from a virtual device, until the mouse is disconnected or
+// ctx is cancelled.
+func debounce(ctx context.Context, f *filter) error {
+	path, caps, err := findButtonsNode()
+	if err != nil {
+		return err
+	}
+
+	node, err := evdev.Open(path)
+	if err != nil {
+		return err
+	}
+	defer node.Close()
+
+	stop := context.AfterFunc(ctx, func() { node.Close() })
+	defer stop()
+
+	out, err := evdev.CreateUInput(uinputName, device.HoltekVendorId, device.AnkerMouseDeviceId, caps)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := node.Grab(); err != nil {
+		return err
+	}
+	log.Printf("Filtering %v", path)
+
+	type read struct {
+		ev  evdev.Event
+		err error
+	}
+	reads := make(chan read)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			ev, err := node.Read()
+			select {
+			case reads <- read{ev, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		// Wake up when a release held back is due, even if the mouse
+		// sends nothing else.
+		var due <-chan time.Time
+		if deadline, ok := f.nextDeadline(); ok {
+			due = time.After(time.Until(deadline))
+		}
+
+		var events []evdev.Event
+		select {
+		case r := <-reads:
+			if r.err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return r.err
+			}
+			events = f.process(r.ev)
+		case now := <-due:
+			events = f.expire(now)
+		}
+
+		for _, ev := range events {
+			if err := out.Write(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func filterRecording(f *filter) error {
+	in, err := os.Open(*recording)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var w io.Writer = io.Discard
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	r := evdev.NewReader(in)
+	for {
+		ev, err := r.Read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var events []evdev.Event
+		if err == io.EOF {
+			events = f.flush()
+		} else {
+			events = f.process(ev)
+		}
+
+		for _, ev := range events {
+			if err := evdev.Write(w, ev); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	f := newFilter(*threshold)
+	if err := f.parseThresholds(*buttonThreshold); err != nil {
+		log.Fatal(err)
+	}
+
+	if *recording != "" {
+		if err := filterRecording(f); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(f.report())
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *statsInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(*statsInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Print(f.report())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	events, err := device.Watch(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for ev := range events {
+		if ev != device.DeviceConnected {
+			continue
+		}
+
+		go func() {
+			if err := debounce(ctx, f); err != nil {
+				log.Printf("Stopped filtering: %v", err)
+			}
+		}()
+	}
+
+	log.Print(f.report())
+}
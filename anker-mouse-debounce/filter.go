@@ -0,0 +1,270 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/evdev"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var buttonNames = map[string]uint16{
+	"left":   evdev.BtnLeft,
+	"right":  evdev.BtnRight,
+	"middle": evdev.BtnMiddle,
+	"side":   evdev.BtnSide,
+	"extra":  evdev.BtnExtra,
+}
+
+// The mouse buttons, BTN_MOUSE to BTN_TASK.
+const (
+	firstButton = 0x110
+	lastButton  = 0x117
+)
+
+func buttonName(code uint16) string {
+	for name, c := range buttonNames {
+		if c == code {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%#03x", code)
+}
+
+type buttonStats struct {
+	presses    int
+	suppressed int
+	// The shortest interval between a release and a press that was
+	// suppressed, to tune the thresholds.
+	shortest time.Duration
+}
+
+// filter drops the presses of a button that come sooner than its
+// threshold after it was released, together with that release: with
+// worn switches, a single click can be seen as two, and a held button
+// can be seen as released for a moment, ending a drag. Releases are
+// held back for the threshold, and only passed on if no press follows
+// them in the meantime.
+type filter struct {
+	mu sync.Mutex
+
+	defaultThreshold time.Duration
+	thresholds       map[uint16]time.Duration
+
+	// The releases held back, by button.
+	pending map[uint16]evdev.Event
+	stats   map[uint16]*buttonStats
+}
+
+func newFilter(defaultThreshold time.Duration) *filter {
+	return &filter{
+		defaultThreshold: defaultThreshold,
+		thresholds:       make(map[uint16]time.Duration),
+		pending:          make(map[uint16]evdev.Event),
+		stats:            make(map[uint16]*buttonStats),
+	}
+}
+
+// parseThresholds parses per-button thresholds, e.g.
+// "left=60ms,side=0".
+func (self *filter) parseThresholds(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Invalid threshold %q: must be button=duration", item)
+		}
+
+		code, ok := buttonNames[kv[0]]
+		if !ok {
+			return fmt.Errorf("Unknown button %q", kv[0])
+		}
+
+		d, err := time.ParseDuration(kv[1])
+		if err != nil {
+			return fmt.Errorf("Invalid threshold for %v: %v", kv[0], err)
+		}
+
+		self.thresholds[code] = d
+	}
+
+	return nil
+}
+
+func (self *filter) threshold(code uint16) time.Duration {
+	if d, ok := self.thresholds[code]; ok {
+		return d
+	}
+
+	return self.defaultThreshold
+}
+
+func (self *filter) buttonStats(code uint16) *buttonStats {
+	s, ok := self.stats[code]
+	if !ok {
+		s = new(buttonStats)
+		self.stats[code] = s
+	}
+
+	return s
+}
+
+// process filters an event, returning the events to pass on in its
+// place: none, the event itself, or the releases held back that are no
+// longer followed by a press within their threshold, before it.
+func (self *filter) process(ev evdev.Event) []evdev.Event {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	events := self.expireLocked(ev.Time, false)
+
+	if ev.Type != evdev.EvKey || ev.Code < firstButton || ev.Code > lastButton {
+		return append(events, ev)
+	}
+
+	code := ev.Code
+	threshold := self.threshold(code)
+	stats := self.buttonStats(code)
+
+	switch ev.Value {
+	case 1:
+		if release, ok := self.pending[code]; ok {
+			// The button was never really released.
+			delete(self.pending, code)
+			since := ev.Time.Sub(release.Time)
+			stats.suppressed++
+			if stats.shortest == 0 || since < stats.shortest {
+				stats.shortest = since
+			}
+			return events
+		}
+		stats.presses++
+
+	case 0:
+		if threshold > 0 {
+			self.pending[code] = ev
+			return events
+		}
+	}
+
+	return append(events, ev)
+}
+
+// expire returns the releases held back whose threshold has passed by
+// now, each followed by a synchronization event.
+func (self *filter) expire(now time.Time) []evdev.Event {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.expireLocked(now, false)
+}
+
+// flush returns all the releases held back, as at the end of a
+// recording.
+func (self *filter) flush() []evdev.Event {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.expireLocked(time.Time{}, true)
+}
+
+// expireLocked returns the releases held back whose threshold has
+// passed by now (or all of them), in order. They are timed at the end
+// of their threshold, when they are passed on.
+func (self *filter) expireLocked(now time.Time, all bool) []evdev.Event {
+	var releases []evdev.Event
+	for code, ev := range self.pending {
+		deadline := ev.Time.Add(self.threshold(code))
+		if !all && deadline.After(now) {
+			continue
+		}
+
+		delete(self.pending, code)
+		ev.Time = deadline
+		releases = append(releases, ev)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Time.Equal(releases[j].Time) {
+			return releases[i].Code < releases[j].Code
+		}
+		return releases[i].Time.Before(releases[j].Time)
+	})
+
+	var events []evdev.Event
+	for _, ev := range releases {
+		events = append(events, ev, evdev.Event{Time: ev.Time, Type: evdev.EvSyn, Code: evdev.SynReport})
+	}
+
+	return events
+}
+
+// nextDeadline returns when the first release held back is due, if
+// any.
+func (self *filter) nextDeadline() (time.Time, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var next time.Time
+	for code, ev := range self.pending {
+		if deadline := ev.Time.Add(self.threshold(code)); next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+func (self *filter) report() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var codes []int
+	for code := range self.stats {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	var lines []string
+	for _, code := range codes {
+		s := self.stats[uint16(code)]
+		line := fmt.Sprintf("%v: %v presses, %v suppressed", buttonName(uint16(code)), s.presses, s.suppressed)
+		if s.suppressed > 0 {
+			line += fmt.Sprintf(" (%.1f%%, shortest %v)", 100*float64(s.suppressed)/float64(s.presses+s.suppressed), s.shortest)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return "no button presses"
+	}
+
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,301 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/evdev"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvtest reads the events in a recording made with evtest, whose
+// text output is easier to review than the raw recordings. Lines other
+// than events are ignored.
+func readEvtest(t *testing.T, path string) []evdev.Event {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []evdev.Event
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "Event: time ") {
+			continue
+		}
+
+		var sec, usec int64
+		var ev evdev.Event
+		if strings.HasSuffix(line, "SYN_REPORT ------------") {
+			_, err = fmt.Sscanf(line, "Event: time %d.%d,", &sec, &usec)
+		} else {
+			var typeName, codeName string
+			_, err = fmt.Sscanf(line, "Event: time %d.%d, type %d %s code %d %s value %d", &sec, &usec, &ev.Type, &typeName, &ev.Code, &codeName, &ev.Value)
+		}
+		if err != nil {
+			t.Fatalf("Invalid line in %v: %q: %v", path, line, err)
+		}
+
+		ev.Time = time.Unix(sec, usec*1000)
+		events = append(events, ev)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+// trace summarises the button changes and movements in the events, in
+// order.
+func trace(events []evdev.Event) []string {
+	var changes []string
+	for _, ev := range events {
+		switch ev.Type {
+		case evdev.EvKey:
+			changes = append(changes, fmt.Sprintf("%v %v", buttonName(ev.Code), ev.Value))
+		case evdev.EvRel:
+			changes = append(changes, "rel")
+		}
+	}
+
+	return changes
+}
+
+// keyEvents counts the button changes in the events.
+func keyEvents(events []evdev.Event) int {
+	n := 0
+	for _, ev := range events {
+		if ev.Type == evdev.EvKey {
+			n++
+		}
+	}
+
+	return n
+}
+
+// buttonChanges returns the button presses (1) and releases (0) in the
+// events, by button.
+func buttonChanges(events []evdev.Event) map[uint16][]int32 {
+	changes := make(map[uint16][]int32)
+	for _, ev := range events {
+		if ev.Type == evdev.EvKey {
+			changes[ev.Code] = append(changes[ev.Code], ev.Value)
+		}
+	}
+
+	return changes
+}
+
+func TestFilterPass(t *testing.T) {
+	tests := []struct {
+		recording       string
+		threshold       time.Duration
+		buttonThreshold string
+		want            map[uint16][]int32
+		wantDropped     int // Button changes dropped.
+		wantReport      string
+		wantTrace       []string
+	}{
+		{
+			recording:  "clean-clicks.txt",
+			threshold:  40 * time.Millisecond,
+			want:       map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0, 1, 0}},
+			wantReport: "left: 3 presses, 0 suppressed",
+		},
+		{
+			recording:   "bouncing-left.txt",
+			threshold:   40 * time.Millisecond,
+			want:        map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0}},
+			wantDropped: 2,
+			wantReport:  "left: 2 presses, 1 suppressed (33.3%, shortest 15ms)",
+		},
+		{
+			recording:   "bouncing-left.txt",
+			threshold:   10 * time.Millisecond,
+			want:        map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0, 1, 0}},
+			wantDropped: 0,
+			wantReport:  "left: 3 presses, 0 suppressed",
+		},
+		{
+			recording:       "bouncing-left.txt",
+			threshold:       40 * time.Millisecond,
+			buttonThreshold: "left=0",
+			want:            map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0, 1, 0}},
+			wantReport:      "left: 3 presses, 0 suppressed",
+		},
+		{
+			recording:  "double-click.txt",
+			threshold:  40 * time.Millisecond,
+			want:       map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0}},
+			wantReport: "left: 2 presses, 0 suppressed",
+		},
+		{
+			recording:   "double-click.txt",
+			threshold:   150 * time.Millisecond,
+			want:        map[uint16][]int32{evdev.BtnLeft: {1, 0}},
+			wantDropped: 2,
+			wantReport:  "left: 1 presses, 1 suppressed (50.0%, shortest 100ms)",
+		},
+		{
+			recording: "drag-with-bounce.txt",
+			threshold: 40 * time.Millisecond,
+			want: map[uint16][]int32{
+				evdev.BtnLeft:  {1, 0},
+				evdev.BtnRight: {1, 0},
+			},
+			wantDropped: 4,
+			wantReport:  "left: 1 presses, 2 suppressed (66.7%, shortest 8ms); right: 1 presses, 0 suppressed",
+		},
+		{
+			recording:   "held-drag-bounce.txt",
+			threshold:   40 * time.Millisecond,
+			want:        map[uint16][]int32{evdev.BtnLeft: {1, 0}},
+			wantDropped: 2,
+			wantReport:  "left: 1 presses, 1 suppressed (50.0%, shortest 6ms)",
+			// The drag lasts until the real release.
+			wantTrace: []string{"left 1", "rel", "rel", "rel", "rel", "rel", "rel", "rel", "left 0", "rel"},
+		},
+		{
+			recording:  "held-drag-bounce.txt",
+			threshold:  5 * time.Millisecond,
+			want:       map[uint16][]int32{evdev.BtnLeft: {1, 0, 1, 0}},
+			wantReport: "left: 2 presses, 0 suppressed",
+			wantTrace:  []string{"left 1", "rel", "rel", "rel", "rel", "left 0", "left 1", "rel", "rel", "rel", "left 0", "rel"},
+		},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%v/%v/%v", tt.recording, tt.threshold, tt.buttonThreshold)
+		t.Run(name, func(t *testing.T) {
+			events := readEvtest(t, filepath.Join("testdata", tt.recording))
+
+			f := newFilter(tt.threshold)
+			if err := f.parseThresholds(tt.buttonThreshold); err != nil {
+				t.Fatal(err)
+			}
+
+			var passed []evdev.Event
+			for _, ev := range events {
+				passed = append(passed, f.process(ev)...)
+			}
+			passed = append(passed, f.flush()...)
+
+			for i := 1; i < len(passed); i++ {
+				if passed[i].Time.Before(passed[i-1].Time) {
+					t.Errorf("Event %v passed after %v", passed[i], passed[i-1])
+				}
+			}
+
+			if got := buttonChanges(passed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Button changes passed: %v, want %v", got, tt.want)
+			}
+			if dropped := keyEvents(events) - keyEvents(passed); dropped != tt.wantDropped {
+				t.Errorf("%v button changes dropped, want %v", dropped, tt.wantDropped)
+			}
+			if got := trace(passed); tt.wantTrace != nil && !reflect.DeepEqual(got, tt.wantTrace) {
+				t.Errorf("Events passed: %v, want %v", got, tt.wantTrace)
+			}
+			if report := f.report(); report != tt.wantReport {
+				t.Errorf("Report %q, want %q", report, tt.wantReport)
+			}
+		})
+	}
+}
+
+func TestFilterExpire(t *testing.T) {
+	f := newFilter(40 * time.Millisecond)
+	start := time.Unix(1700000000, 0)
+
+	press := evdev.Event{Time: start, Type: evdev.EvKey, Code: evdev.BtnLeft, Value: 1}
+	if got := f.process(press); !reflect.DeepEqual(got, []evdev.Event{press}) {
+		t.Fatalf("Press passed as %v", got)
+	}
+
+	release := evdev.Event{Time: start.Add(100 * time.Millisecond), Type: evdev.EvKey, Code: evdev.BtnLeft, Value: 0}
+	if got := f.process(release); len(got) != 0 {
+		t.Fatalf("Release passed right away as %v", got)
+	}
+
+	due := release.Time.Add(40 * time.Millisecond)
+	if deadline, ok := f.nextDeadline(); !ok || !deadline.Equal(due) {
+		t.Errorf("Next deadline %v (%v), want %v", deadline, ok, due)
+	}
+	if got := f.expire(due.Add(-time.Millisecond)); len(got) != 0 {
+		t.Errorf("Release passed before its deadline as %v", got)
+	}
+
+	want := []evdev.Event{
+		{Time: due, Type: evdev.EvKey, Code: evdev.BtnLeft, Value: 0},
+		{Time: due, Type: evdev.EvSyn, Code: evdev.SynReport},
+	}
+	if got := f.expire(due); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expired %v, want %v", got, want)
+	}
+	if _, ok := f.nextDeadline(); ok {
+		t.Error("Release still held back after expiring")
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[uint16]time.Duration
+		err   string
+	}{
+		{"", map[uint16]time.Duration{}, ""},
+		{"left=60ms,side=0", map[uint16]time.Duration{evdev.BtnLeft: 60 * time.Millisecond, evdev.BtnSide: 0}, ""},
+		{"left", nil, "must be button=duration"},
+		{"wheel=10ms", nil, "Unknown button"},
+		{"left=fast", nil, "Invalid threshold for left"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f := newFilter(time.Second)
+			err := f.parseThresholds(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseThresholds(%q) = %v, want error containing %q", tt.value, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.thresholds, tt.want) {
+				t.Errorf("Thresholds %v, want %v", f.thresholds, tt.want)
+			}
+		})
+	}
+}
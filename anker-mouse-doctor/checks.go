@@ -0,0 +1,206 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const sysUsbDevices = "/sys/bus/usb/devices"
+
+func readSysfs(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+type usbInterface struct {
+	name   string // e.g. 1-2:1.0
+	class  string // bInterfaceClass, bInterfaceSubClass, bInterfaceProtocol
+	driver string
+	hidraw []string
+}
+
+type usbDevice struct {
+	name       string // e.g. 1-2
+	devnode    string // /dev/bus/usb/BBB/DDD
+	interfaces []*usbInterface
+}
+
+// findUsbDevices looks for the mouse in sysfs, without going through
+// hidapi.
+func findUsbDevices() []*usbDevice {
+	var devs []*usbDevice
+
+	entries, _ := os.ReadDir(sysUsbDevices)
+	for _, e := range entries {
+		dir := filepath.Join(sysUsbDevices, e.Name())
+		if readSysfs(filepath.Join(dir, "idVendor")) != fmt.Sprintf("%04x", device.HoltekVendorId) ||
+			readSysfs(filepath.Join(dir, "idProduct")) != fmt.Sprintf("%04x", device.AnkerMouseDeviceId) {
+			continue
+		}
+
+		dev := &usbDevice{name: e.Name()}
+
+		busnum, err1 := strconv.Atoi(readSysfs(filepath.Join(dir, "busnum")))
+		devnum, err2 := strconv.Atoi(readSysfs(filepath.Join(dir, "devnum")))
+		if err1 == nil && err2 == nil {
+			dev.devnode = fmt.Sprintf("/dev/bus/usb/%03d/%03d", busnum, devnum)
+		}
+
+		intfs, _ := filepath.Glob(filepath.Join(sysUsbDevices, e.Name()+":*"))
+		for _, i := range intfs {
+			intf := &usbInterface{
+				name: filepath.Base(i),
+				class: fmt.Sprintf("%s/%s/%s",
+					readSysfs(filepath.Join(i, "bInterfaceClass")),
+					readSysfs(filepath.Join(i, "bInterfaceSubClass")),
+					readSysfs(filepath.Join(i, "bInterfaceProtocol"))),
+			}
+
+			if driver, err := os.Readlink(filepath.Join(i, "driver")); err == nil {
+				intf.driver = filepath.Base(driver)
+			}
+
+			nodes, _ := filepath.Glob(filepath.Join(i, "*", "hidraw", "hidraw*"))
+			for _, n := range nodes {
+				intf.hidraw = append(intf.hidraw, filepath.Join("/dev", filepath.Base(n)))
+			}
+
+			dev.interfaces = append(dev.interfaces, intf)
+		}
+
+		devs = append(devs, dev)
+	}
+
+	return devs
+}
+
+// checkAccess tries opening the node for reading and writing, which is
+// what hidapi needs to send feature reports.
+func checkAccess(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// hidapiLibraries returns the hidapi libraries mapped in the current
+// process, which is linked against hidapi through the device package.
+func hidapiLibraries() []string {
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var libs []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		path := fields[5]
+		if strings.Contains(filepath.Base(path), "hidapi") && !seen[path] {
+			seen[path] = true
+			libs = append(libs, path)
+		}
+	}
+
+	return libs
+}
+
+// installedHidapiLibraries lists the hidapi libraries known to the
+// dynamic linker.
+func installedHidapiLibraries() []string {
+	out, err := exec.Command("ldconfig", "-p").Output()
+	if err != nil {
+		return nil
+	}
+
+	var libs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "libhidapi") {
+			libs = append(libs, strings.Fields(line)[0])
+		}
+	}
+
+	return libs
+}
+
+type holder struct {
+	pid  int
+	comm string
+	path string
+}
+
+// findHolders returns the processes that have any of the paths open.
+func findHolders(paths []string) []holder {
+	wanted := make(map[string]bool)
+	for _, p := range paths {
+		wanted[p] = true
+	}
+
+	var holders []holder
+
+	procs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(filepath.Base(proc))
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		fds, _ := filepath.Glob(filepath.Join(proc, "fd", "*"))
+		for _, fd := range fds {
+			target, err := os.Readlink(fd)
+			if err != nil || !wanted[target] {
+				continue
+			}
+
+			holders = append(holders, holder{
+				pid:  pid,
+				comm: readSysfs(filepath.Join(proc, "comm")),
+				path: target,
+			})
+		}
+	}
+
+	return holders
+}
@@ -0,0 +1,303 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	udevRuleOnly    = flag.Bool("udev_rule", false, "Only print the udev rule granting access to the mouse, and exit.")
+	dumpDescriptors = flag.String("dump_descriptors", "", "Only save the report descriptors of the mouse interfaces to this directory, for anker-mouse-virtual -descriptors, and exit.")
+)
+
+const udevRulePath = "/etc/udev/rules.d/70-anker-mouse.rules"
+
+// The uaccess tag is processed by 73-seat-late.rules, so the rule has
+// to sort before it.
+var udevRule = fmt.Sprintf(`# Allow the user logged in at the seat to configure the Anker mouse.
+SUBSYSTEM=="hidraw", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", TAG+="uaccess"
+SUBSYSTEM=="usb", ATTR{idVendor}=="%04x", ATTR{idProduct}=="%04x", TAG+="uaccess"
+`, device.HoltekVendorId, device.AnkerMouseDeviceId, device.HoltekVendorId, device.AnkerMouseDeviceId)
+
+type doctor struct {
+	failures   int
+	needsRule  bool
+	remedies   []string
+	seenRemedy map[string]bool
+}
+
+func (self *doctor) remedy(r string) {
+	if r == "" || self.seenRemedy[r] {
+		return
+	}
+	self.seenRemedy[r] = true
+	self.remedies = append(self.remedies, r)
+}
+
+func (self *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("[ OK ] "+format+"\n", args...)
+}
+
+func (self *doctor) warn(remedy string, format string, args ...interface{}) {
+	fmt.Printf("[WARN] "+format+"\n", args...)
+	self.remedy(remedy)
+}
+
+func (self *doctor) fail(remedy string, format string, args ...interface{}) {
+	fmt.Printf("[FAIL] "+format+"\n", args...)
+	self.failures++
+	self.remedy(remedy)
+}
+
+func (self *doctor) checkDevices() []string {
+	var nodes []string
+
+	devs := findUsbDevices()
+	if len(devs) == 0 {
+		self.fail(
+			fmt.Sprintf("Make sure the mouse is plugged in (and selected, if behind a KVM switch); `lsusb -d %04x:%04x` should list it.",
+				device.HoltekVendorId, device.AnkerMouseDeviceId),
+			"No USB device %04x:%04x found in sysfs", device.HoltekVendorId, device.AnkerMouseDeviceId)
+		return nil
+	}
+
+	for _, dev := range devs {
+		self.ok("Found USB device %v (%v)", dev.name, dev.devnode)
+		if dev.devnode != "" {
+			nodes = append(nodes, dev.devnode)
+		}
+
+		for _, intf := range dev.interfaces {
+			switch intf.driver {
+			case "usbhid":
+				self.ok("Interface %v (class %v) is bound to usbhid", intf.name, intf.class)
+			case "":
+				self.fail(
+					"An interface without a driver was usually detached by a program using libusb; stop that program, then unplug and replug the mouse.",
+					"Interface %v (class %v) is not bound to any driver", intf.name, intf.class)
+				continue
+			default:
+				self.warn("",
+					"Interface %v (class %v) is bound to %v rather than usbhid", intf.name, intf.class, intf.driver)
+				continue
+			}
+
+			if len(intf.hidraw) == 0 {
+				self.fail(
+					"Make sure the hidraw module is loaded: `sudo modprobe hidraw`.",
+					"Interface %v has no hidraw node", intf.name)
+			}
+
+			for _, node := range intf.hidraw {
+				nodes = append(nodes, node)
+
+				err := checkAccess(node)
+				switch {
+				case err == nil:
+					self.ok("%v is readable and writable", node)
+				case os.IsNotExist(err):
+					self.fail(
+						"The hidraw device nodes are created by udev (or devtmpfs); check that it is running.",
+						"%v does not exist", node)
+				case os.IsPermission(err):
+					self.needsRule = true
+					self.fail("",
+						"%v is not readable and writable by the current user", node)
+				default:
+					self.fail("", "%v cannot be opened: %v", node, err)
+				}
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (self *doctor) checkDescriptors() {
+	descs, err := device.InterfaceDescriptors()
+	if err != nil {
+		self.fail("", "Unable to read the report descriptors: %v", err)
+		return
+	}
+
+	found := false
+	for intf, desc := range descs {
+		var ids []string
+		for _, id := range []byte{2, 3, 4} {
+			if size, ok := desc.Feature[id]; ok {
+				ids = append(ids, fmt.Sprintf("%v (%v bytes)", id, size))
+				found = true
+			}
+		}
+
+		if len(ids) > 0 {
+			self.ok("Interface %v declares feature reports %v", intf, strings.Join(ids, ", "))
+		}
+	}
+
+	if len(descs) > 0 && !found {
+		self.fail(
+			"This might be a different device sharing the same USB identifiers.",
+			"No interface declares the configuration feature reports")
+	}
+}
+
+func (self *doctor) checkHidapi() {
+	hidapiBuilt := false
+	for _, name := range device.Backends() {
+		if name == "hidapi" {
+			hidapiBuilt = true
+		}
+	}
+	if !hidapiBuilt {
+		self.ok("hidapi is not needed by this build")
+		return
+	}
+
+	if libs := hidapiLibraries(); len(libs) > 0 {
+		self.ok("hidapi is loaded from %v", strings.Join(libs, ", "))
+		return
+	}
+
+	// Statically linked, or not linked at all; it might still be
+	// needed by other builds of the tools.
+	if libs := installedHidapiLibraries(); len(libs) > 0 {
+		self.ok("hidapi is installed (%v)", strings.Join(libs, ", "))
+		return
+	}
+
+	self.warn(
+		"Install hidapi, e.g. `sudo apt install libhidapi-hidraw0` or `sudo dnf install hidapi`.",
+		"hidapi is neither loaded nor known to the dynamic linker")
+}
+
+func (self *doctor) checkHolders(nodes []string) {
+	for _, h := range findHolders(nodes) {
+		self.warn(
+			"Other programs talking to the mouse at the same time can interfere with the tools; try stopping them if they fail.",
+			"%v is open in process %v (%v)", h.path, h.pid, h.comm)
+	}
+}
+
+func (self *doctor) checkOpen() {
+	for _, name := range device.Backends() {
+		dev, err := device.OpenBackend(name)
+		if err != nil {
+			self.fail("", "Opening the device through the %v backend failed: %v", name, err)
+			continue
+		}
+		dev.Close()
+
+		self.ok("The device can be opened through the %v backend", name)
+	}
+
+	self.ok("The tools use the %v backend (set %v to change)", device.DefaultBackend(), device.BackendEnv)
+}
+
+// saveDescriptors writes the report descriptor of each interface of
+// the mouse to dir, as interfaceN.bin.
+func saveDescriptors(dir string) error {
+	descs, err := device.RawInterfaceDescriptors()
+	if err != nil {
+		return err
+	}
+	if len(descs) == 0 {
+		return fmt.Errorf("No report descriptors found; make sure the mouse is plugged in")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var intfs []int
+	for intf := range descs {
+		intfs = append(intfs, intf)
+	}
+	sort.Ints(intfs)
+
+	for _, intf := range intfs {
+		path := filepath.Join(dir, fmt.Sprintf("interface%d.bin", intf))
+		if err := os.WriteFile(path, descs[intf], 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Saved the report descriptor of interface %v to %v\n", intf, path)
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *udevRuleOnly {
+		fmt.Print(udevRule)
+		return
+	}
+
+	if *dumpDescriptors != "" {
+		if err := saveDescriptors(*dumpDescriptors); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	d := &doctor{
+		seenRemedy: make(map[string]bool),
+	}
+
+	nodes := d.checkDevices()
+	d.checkDescriptors()
+	d.checkHidapi()
+	d.checkHolders(nodes)
+	d.checkOpen()
+
+	if d.needsRule {
+		d.remedy(fmt.Sprintf(`Grant access to the mouse to the logged-in user with a udev rule:
+
+    %v -udev_rule | sudo tee %v
+    sudo udevadm control --reload
+    sudo udevadm trigger
+
+then unplug and replug the mouse.`, os.Args[0], udevRulePath))
+	}
+
+	if len(d.remedies) > 0 {
+		fmt.Println()
+		fmt.Println("Suggestions:")
+		for _, r := range d.remedies {
+			fmt.Printf("\n * %v\n", r)
+		}
+	}
+
+	if d.failures > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+)
+
+var (
+	stage = flag.Int("stage", 0, "Select DPI stage 1 to 4 of the active profile, rather than showing the active one.")
+)
+
+func main() {
+	flag.Parse()
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *stage == 0 {
+		s, err := dev.ActiveDPIStage()
+		if err != nil {
+			log.Fatalf("Unable to read the active DPI stage: %v", err)
+		}
+
+		fmt.Println(s)
+		return
+	}
+
+	s, err := device.NewDPIStage(*stage)
+	if err != nil {
+		log.Fatalf("Invalid value for -stage: %v", err)
+	}
+
+	err = dev.SetDPIStage(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
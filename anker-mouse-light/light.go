@@ -38,12 +38,14 @@ var (
 func main() {
 	flag.Parse()
 
-	if *brightness < 0 || *brightness > 3 {
-		log.Fatalf("Invalid value for -brightness: %v", *brightness)
+	b, err := device.NewBrightness(*brightness)
+	if err != nil {
+		log.Fatalf("Invalid value for -brightness: %v", err)
 	}
 
-	if *breathSpeed < 0 || *breathSpeed > 3 {
-		log.Fatalf("Invalid value for -breath_speed: %v", *breathSpeed)
+	s, err := device.NewBreathSpeed(*breathSpeed)
+	if err != nil {
+		log.Fatalf("Invalid value for -breath_speed: %v", err)
 	}
 
 	c, err := colorful.Hex(*lightColor)
@@ -56,7 +58,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	err = dev.SetLight(c, byte(*brightness), byte(*breathSpeed))
+	err = dev.SetLight(c, b, s)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,160 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Intervals longer than this are taken as the mouse not moving,
+	// rather than reports being lost.
+	pauseThreshold = 100 * time.Millisecond
+
+	// Intervals this many times longer than expected are counted as
+	// dropped reports.
+	dropFactor = 1.5
+
+	histogramBuckets = 16
+	histogramWidth   = 50
+)
+
+type analysis struct {
+	expected  time.Duration
+	intervals []time.Duration
+
+	dropped       int // Intervals with missing reports,
+	droppedEvents int // and how many reports are estimated missing.
+}
+
+// analyse computes the intervals between the motion reports, leaving
+// out the pauses in the movement.
+func analyse(events []device.Event, rate device.PollingRate) *analysis {
+	a := &analysis{
+		expected: time.Second / time.Duration(rate),
+	}
+
+	var last time.Time
+	for _, ev := range events {
+		if ev.Kind != device.MotionEvent {
+			continue
+		}
+
+		if !last.IsZero() {
+			d := ev.Time.Sub(last)
+			if d < pauseThreshold {
+				a.intervals = append(a.intervals, d)
+
+				if float64(d) >= dropFactor*float64(a.expected) {
+					a.dropped++
+					a.droppedEvents += int(math.Round(float64(d)/float64(a.expected))) - 1
+				}
+			}
+		}
+		last = ev.Time
+	}
+
+	return a
+}
+
+func (self *analysis) mean() time.Duration {
+	var total time.Duration
+	for _, d := range self.intervals {
+		total += d
+	}
+
+	return total / time.Duration(len(self.intervals))
+}
+
+func (self *analysis) stddev() time.Duration {
+	mean := float64(self.mean())
+
+	var sum float64
+	for _, d := range self.intervals {
+		sum += (float64(d) - mean) * (float64(d) - mean)
+	}
+
+	return time.Duration(math.Sqrt(sum / float64(len(self.intervals))))
+}
+
+func (self *analysis) percentile(p float64) time.Duration {
+	sorted := append([]time.Duration(nil), self.intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// printHistogram shows the intervals up to three times the expected
+// one; the longer ones are in the last bucket.
+func (self *analysis) printHistogram(w io.Writer) {
+	limit := 3 * self.expected
+	width := limit / histogramBuckets
+
+	var counts [histogramBuckets]int
+	max := 0
+	for _, d := range self.intervals {
+		b := int(d / width)
+		if b >= histogramBuckets {
+			b = histogramBuckets - 1
+		}
+		counts[b]++
+		if counts[b] > max {
+			max = counts[b]
+		}
+	}
+
+	for b, n := range counts {
+		label := fmt.Sprintf("%8v", width*time.Duration(b))
+		if b == histogramBuckets-1 {
+			label += "+"
+		} else {
+			label += " "
+		}
+
+		fmt.Fprintf(w, "%v %-*v %v\n", label, histogramWidth, strings.Repeat("#", n*histogramWidth/max), n)
+	}
+}
+
+func (self *analysis) print(w io.Writer, rate device.PollingRate) {
+	if len(self.intervals) == 0 {
+		fmt.Fprintln(w, "No movement recorded.")
+		return
+	}
+
+	mean := self.mean()
+	fmt.Fprintf(w, "Configured rate: %v (every %v)\n", rate, self.expected)
+	fmt.Fprintf(w, "Achieved rate:   %.0f Hz (every %v on average)\n", float64(time.Second)/float64(mean), mean)
+	fmt.Fprintf(w, "Jitter:          %v standard deviation; median %v, 99th percentile %v\n", self.stddev(), self.percentile(0.5), self.percentile(0.99))
+	fmt.Fprintf(w, "Dropped:         %v intervals over %.1fx the expected one, about %v reports missing (%.2f%%)\n",
+		self.dropped, dropFactor, self.droppedEvents, 100*float64(self.droppedEvents)/float64(len(self.intervals)+self.droppedEvents))
+	fmt.Fprintln(w)
+	self.printHistogram(w)
+}
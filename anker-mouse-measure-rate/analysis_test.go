@@ -0,0 +1,150 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readTestTrace(t *testing.T, name string) []device.Event {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := device.ReadTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestAnalyse(t *testing.T) {
+	tests := []struct {
+		trace string
+		rate  device.PollingRate
+
+		intervals     int
+		mean          time.Duration
+		stddev        time.Duration
+		median        time.Duration
+		p99           time.Duration
+		dropped       int
+		droppedEvents int
+	}{
+		{
+			trace: "steady-500hz.jsonl", rate: device.PollingRate500Hz,
+			intervals: 100, mean: 2 * time.Millisecond, median: 2 * time.Millisecond, p99: 2 * time.Millisecond,
+		},
+		{
+			trace: "jitter-500hz.jsonl", rate: device.PollingRate500Hz,
+			intervals: 100, mean: 2 * time.Millisecond, stddev: 250 * time.Microsecond,
+			median: 1750 * time.Microsecond, p99: 2250 * time.Microsecond,
+		},
+		{
+			// 95 intervals of 2ms, two of 4ms and one of 6ms; the pause
+			// between the two movements is not an interval.
+			trace: "drops-500hz.jsonl", rate: device.PollingRate500Hz,
+			intervals: 98, mean: 204 * time.Millisecond / 98, stddev: 488092 * time.Nanosecond,
+			median: 2 * time.Millisecond, p99: 4 * time.Millisecond,
+			dropped: 3, droppedEvents: 4,
+		},
+		{
+			// Faster than configured is not dropping anything.
+			trace: "steady-1000hz.jsonl", rate: device.PollingRate500Hz,
+			intervals: 99, mean: time.Millisecond, median: time.Millisecond, p99: time.Millisecond,
+		},
+		{
+			// At 125 Hz, 2ms intervals are nowhere near dropping.
+			trace: "drops-500hz.jsonl", rate: device.PollingRate125Hz,
+			intervals: 98, mean: 204 * time.Millisecond / 98, stddev: 488092 * time.Nanosecond,
+			median: 2 * time.Millisecond, p99: 4 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.trace+"/"+tt.rate.String(), func(t *testing.T) {
+			a := analyse(readTestTrace(t, tt.trace), tt.rate)
+
+			if len(a.intervals) != tt.intervals {
+				t.Fatalf("%v intervals, want %v", len(a.intervals), tt.intervals)
+			}
+			if got := a.mean(); got != tt.mean {
+				t.Errorf("Mean %v, want %v", got, tt.mean)
+			}
+			if got := a.stddev(); got != tt.stddev {
+				t.Errorf("Standard deviation %v, want %v", got, tt.stddev)
+			}
+			if got := a.percentile(0.5); got != tt.median {
+				t.Errorf("Median %v, want %v", got, tt.median)
+			}
+			if got := a.percentile(0.99); got != tt.p99 {
+				t.Errorf("99th percentile %v, want %v", got, tt.p99)
+			}
+			if a.dropped != tt.dropped || a.droppedEvents != tt.droppedEvents {
+				t.Errorf("Dropped %v intervals, %v reports; want %v, %v", a.dropped, a.droppedEvents, tt.dropped, tt.droppedEvents)
+			}
+		})
+	}
+}
+
+func TestAnalysisPrint(t *testing.T) {
+	tests := []struct {
+		trace string
+		want  []string
+	}{
+		{"drops-500hz.jsonl", []string{
+			"Configured rate: 500 Hz (every 2ms)\n",
+			"Achieved rate:   480 Hz (every 2.081632ms on average)\n",
+			"Dropped:         3 intervals over 1.5x the expected one, about 4 reports missing (3.92%)\n",
+			"Jitter:          488.092µs standard deviation; median 2ms, 99th percentile 4ms\n",
+			" 1.875ms  " + strings.Repeat("#", 50) + " 95\n",
+			"  3.75ms  #" + strings.Repeat(" ", 49) + " 2\n",
+			"5.625ms+ " + strings.Repeat(" ", 50) + " 1\n",
+		}},
+		{"buttons-only.jsonl", []string{"No movement recorded.\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.trace, func(t *testing.T) {
+			var buf bytes.Buffer
+			analyse(readTestTrace(t, tt.trace), device.PollingRate500Hz).print(&buf, device.PollingRate500Hz)
+
+			for _, line := range tt.want {
+				if !strings.Contains(buf.String(), line) {
+					t.Errorf("Output does not contain %q:\n%v", line, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+	"time"
+)
+
+var (
+	duration  = flag.Duration("duration", 5*time.Second, "How long to measure for, while the mouse is moved.")
+	traceFile = flag.String("trace", "", "Analyse this trace rather than the mouse.")
+	record    = flag.String("record", "", "Record the measured events to this trace file.")
+	rateFlag  = flag.Int("rate", 0, "Polling rate in Hz to compare with, rather than the configured one.")
+)
+
+// configuredRate returns the polling rate the measurement is compared
+// with: the one given on the command line, the one read from the
+// mouse (only with the experimental features), or the last one written
+// by these tools.
+func configuredRate(dev *device.Device) device.PollingRate {
+	if *rateFlag != 0 {
+		rate, err := device.NewPollingRate(*rateFlag)
+		if err != nil {
+			log.Fatalf("Invalid value for -rate: %v", err)
+		}
+		return rate
+	}
+
+	if dev != nil && device.Experimental() {
+		rate, err := dev.PollingRate()
+		if err == nil {
+			return rate
+		}
+		log.Printf("Unable to read the polling rate: %v", err)
+	}
+
+	cfg, err := device.LoadLastConfig()
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+
+	if cfg == nil || cfg.PollingRate == 0 {
+		log.Printf("No polling rate was written by these tools; comparing with the default %v.", device.DefaultPollingRate)
+		return device.DefaultPollingRate
+	}
+
+	return cfg.PollingRate
+}
+
+func readTrace() []device.Event {
+	f, err := os.Open(*traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := device.ReadTrace(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return events
+}
+
+func measure(dev *device.Device) []device.Event {
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	stream, err := dev.Events(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Keep moving the mouse for %v...\n", *duration)
+
+	var events []device.Event
+	for ev := range stream {
+		if ev.Kind == device.MotionEvent {
+			events = append(events, ev)
+		}
+	}
+
+	return events
+}
+
+func main() {
+	flag.Parse()
+
+	var dev *device.Device
+	var events []device.Event
+
+	if *traceFile != "" {
+		events = readTrace()
+	} else {
+		var err error
+		if dev, err = device.Open(); err != nil {
+			log.Fatal(err)
+		}
+		events = measure(dev)
+	}
+
+	if *record != "" {
+		out, err := os.Create(*record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, ev := range events {
+			if err := device.WriteTraceEvent(out, ev); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rate := configuredRate(dev)
+	analyse(events, rate).print(os.Stdout, rate)
+}
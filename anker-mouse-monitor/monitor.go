@@ -0,0 +1,170 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/evdev"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	hideMotion = flag.Bool("hide_motion", false, "Do not show movement events.")
+	showEvdev  = flag.Bool("evdev", false, "Also show the events reported by the kernel on the event nodes of the mouse.")
+)
+
+// monitor shows the events of the mouse next to the buttons that are
+// bound to produce them.
+type monitor struct {
+	cfg     *device.Config
+	profile device.ProfileID
+}
+
+func (self *monitor) buttons() *device.ButtonsProfile {
+	return self.cfg.Profiles[self.profile-1].ButtonsProfile
+}
+
+func (self *monitor) showBindings() {
+	fmt.Printf("Profile %v:\n", self.profile)
+	for i, b := range self.buttons().Buttons {
+		fmt.Printf("  button %v: %v\n", i+1, b)
+	}
+}
+
+// sources returns the buttons bound to produce the event.
+func (self *monitor) sources(ev device.Event) string {
+	var sources []string
+	for i, b := range self.buttons().Buttons {
+		if b.Produces(ev) {
+			sources = append(sources, fmt.Sprintf("button %v (%v)", i+1, b))
+		}
+	}
+
+	if len(sources) == 0 {
+		if ev.Kind == device.ButtonEvent || ev.Kind == device.KeyEvent {
+			return "not bound to any button"
+		}
+		return ""
+	}
+
+	return strings.Join(sources, ", ")
+}
+
+func (self *monitor) show(ev device.Event) {
+	if ev.Kind == device.MotionEvent && *hideMotion {
+		return
+	}
+
+	line := fmt.Sprintf("%v  %-30v", ev.Time.Format("15:04:05.000000"), ev)
+	if src := self.sources(ev); src != "" {
+		line += "  <- " + src
+	}
+	fmt.Println(strings.TrimRight(line, " "))
+
+	if ev.Kind == device.ProfileChanged {
+		self.profile = ev.Profile
+		self.showBindings()
+	}
+}
+
+func showEvdevEvents(ctx context.Context) {
+	paths, err := evdev.Find(device.HoltekVendorId, device.AnkerMouseDeviceId)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, path := range paths {
+		node, err := evdev.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func(path string, node *evdev.Node) {
+			defer node.Close()
+			for {
+				ev, err := node.Read()
+				if err != nil {
+					log.Printf("Error reading %v: %v", path, err)
+					return
+				}
+
+				if ev.Type == evdev.EvSyn || (ev.Type == evdev.EvRel && *hideMotion && ev.Code <= evdev.RelY) {
+					continue
+				}
+				fmt.Printf("%v  %v\n", path, ev)
+			}
+		}(path, node)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// The configuration cannot be read from the mouse.
+	cfg, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		log.Print("No configuration was written by these tools; showing the default bindings.")
+		cfg = device.NewConfig()
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m := &monitor{cfg: cfg, profile: device.Profile1}
+	if p, err := dev.ActiveProfile(); err == nil {
+		m.profile = p
+	} else {
+		log.Printf("Unable to read the active profile, assuming profile 1: %v", err)
+	}
+	m.showBindings()
+
+	ctx := context.Background()
+	var events <-chan device.Event
+	if device.Experimental() {
+		events, err = dev.EventsWithState(ctx)
+	} else {
+		events, err = dev.Events(ctx)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *showEvdev {
+		showEvdevEvents(ctx)
+	}
+
+	for ev := range events {
+		m.show(ev)
+	}
+
+	log.Print("The mouse was disconnected")
+}
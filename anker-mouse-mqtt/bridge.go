@@ -0,0 +1,398 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"log"
+	"sync"
+)
+
+type rgbColor struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+// lightMessage is the JSON schema used by Home Assistant for both the
+// light state and the light commands.
+type lightMessage struct {
+	State      string    `json:"state"`
+	Brightness *int      `json:"brightness,omitempty"`
+	ColorMode  string    `json:"color_mode,omitempty"`
+	Color      *rgbColor `json:"color,omitempty"`
+	Effect     string    `json:"effect,omitempty"`
+}
+
+type bridge struct {
+	client          mqtt.Client
+	discoveryPrefix string
+	topicPrefix     string
+	nodeId          string
+
+	mu         sync.Mutex
+	dev        *device.Device
+	stopEvents context.CancelFunc
+	// Closed once followEvents returns.
+	eventsDone chan struct{}
+
+	// Desired state as last requested through MQTT. The light is
+	// only written to the device once it has been set at least once,
+	// so that the profile light is otherwise left alone.
+	lightSet    bool
+	on          bool
+	color       colorful.Color
+	brightness  device.Brightness // used when on
+	breathSpeed device.BreathSpeed
+
+	profileSet bool
+	profile    device.ProfileID
+
+	// Whether profile is known to be the active one: either set
+	// through MQTT, or reported by the mouse.
+	profileKnown bool
+}
+
+func newBridge(discoveryPrefix, topicPrefix, nodeId string) *bridge {
+	return &bridge{
+		discoveryPrefix: discoveryPrefix,
+		topicPrefix:     topicPrefix,
+		nodeId:          nodeId,
+		on:              true,
+		color:           colorful.Color{R: 0, G: 0, B: 1},
+		brightness:      2,
+	}
+}
+
+func (self *bridge) topic(name string) string {
+	return fmt.Sprintf("%s/%s/%s", self.topicPrefix, self.nodeId, name)
+}
+
+func (self *bridge) publish(topic string, payload []byte) {
+	token := self.client.Publish(topic, 1, true, payload)
+	token.Wait()
+	if err := token.Error(); err != nil {
+		log.Printf("Error publishing to %v: %v", topic, err)
+	}
+}
+
+func (self *bridge) publishDiscovery() {
+	msgs, err := self.discoveryMessages()
+	if err != nil {
+		log.Printf("Error building discovery messages: %v", err)
+		return
+	}
+
+	for _, m := range msgs {
+		self.publish(m.Topic, m.Payload)
+	}
+}
+
+// onConnect is called by the MQTT client every time the connection to
+// the broker is (re-)established.
+func (self *bridge) onConnect(client mqtt.Client) {
+	self.publish(self.topic("bridge"), []byte("online"))
+
+	subscriptions := map[string]mqtt.MessageHandler{
+		self.topic("light/set"):          self.handleLightCommand,
+		self.topic("profile/set"):        self.handleProfileCommand,
+		self.discoveryPrefix + "/status": self.handleHomeAssistantStatus,
+	}
+	for topic, handler := range subscriptions {
+		token := client.Subscribe(topic, 1, handler)
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("Error subscribing to %v: %v", topic, err)
+		}
+	}
+
+	self.publishDiscovery()
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.publishAvailabilityLocked()
+	self.publishLightLocked()
+	self.publishProfileLocked()
+}
+
+func (self *bridge) handleHomeAssistantStatus(client mqtt.Client, msg mqtt.Message) {
+	// Home Assistant announces itself when it restarts, and expects
+	// discovery messages to be sent again.
+	if string(msg.Payload()) == "online" {
+		self.publishDiscovery()
+	}
+}
+
+func (self *bridge) handleLightCommand(client mqtt.Client, msg mqtt.Message) {
+	var cmd lightMessage
+	if err := json.Unmarshal(msg.Payload(), &cmd); err != nil {
+		log.Printf("Invalid light command %q: %v", msg.Payload(), err)
+		return
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	switch cmd.State {
+	case "ON":
+		self.on = true
+	case "OFF":
+		self.on = false
+	}
+
+	if cmd.Brightness != nil {
+		// brightness_scale is 3 in the discovery message, so Home
+		// Assistant already sends values on the device's scale.
+		switch b := *cmd.Brightness; {
+		case b <= 0:
+			self.on = false
+		case b > int(device.MaxBrightness):
+			self.brightness = device.MaxBrightness
+		default:
+			self.brightness = device.Brightness(b)
+		}
+	}
+
+	if cmd.Color != nil {
+		self.color = colorful.Color{
+			R: float64(cmd.Color.R) / 255.0,
+			G: float64(cmd.Color.G) / 255.0,
+			B: float64(cmd.Color.B) / 255.0,
+		}
+	}
+
+	if cmd.Effect != "" {
+		found := false
+		for i, e := range effectList {
+			if e == cmd.Effect {
+				self.breathSpeed = device.BreathSpeed(i)
+				found = true
+			}
+		}
+		if !found {
+			log.Printf("Unknown light effect %q", cmd.Effect)
+		}
+	}
+
+	self.lightSet = true
+	self.applyLightLocked()
+	self.publishLightLocked()
+}
+
+func (self *bridge) handleProfileCommand(client mqtt.Client, msg mqtt.Message) {
+	option := string(msg.Payload())
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	found := false
+	for i, o := range profileOptions {
+		if o == option {
+			self.profile = device.ProfileID(i + 1)
+			found = true
+		}
+	}
+	if !found {
+		log.Printf("Unknown profile %q", option)
+		return
+	}
+
+	self.profileSet = true
+	self.profileKnown = true
+	self.applyProfileLocked()
+	self.publishProfileLocked()
+}
+
+func (self *bridge) applyLightLocked() {
+	if self.dev == nil || !self.lightSet {
+		return
+	}
+
+	brightness := self.brightness
+	if !self.on {
+		brightness = 0
+	}
+
+	if err := self.dev.SetLight(self.color, brightness, self.breathSpeed); err != nil {
+		log.Printf("Error setting light: %v", err)
+	}
+}
+
+func (self *bridge) applyProfileLocked() {
+	if self.dev == nil || !self.profileSet {
+		return
+	}
+
+	if err := self.dev.SetProfile(self.profile); err != nil {
+		log.Printf("Error setting profile: %v", err)
+	}
+}
+
+func (self *bridge) publishLightLocked() {
+	brightness := int(self.brightness)
+	r, g, b := self.color.RGB255()
+
+	state := lightMessage{
+		State:      "OFF",
+		Brightness: &brightness,
+		ColorMode:  "rgb",
+		Color:      &rgbColor{r, g, b},
+		Effect:     effectList[self.breathSpeed],
+	}
+	if self.on {
+		state.State = "ON"
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Error encoding light state: %v", err)
+		return
+	}
+
+	self.publish(self.topic("light"), payload)
+}
+
+func (self *bridge) publishProfileLocked() {
+	// Reading the active profile back from the mouse is experimental;
+	// without it, the profile is only known once set through the
+	// bridge.
+	if !self.profileKnown {
+		return
+	}
+
+	self.publish(self.topic("profile"), []byte(profileOptions[self.profile-1]))
+}
+
+func (self *bridge) publishAvailabilityLocked() {
+	availability := "offline"
+	if self.dev != nil {
+		availability = "online"
+	}
+
+	self.publish(self.topic("availability"), []byte(availability))
+}
+
+// handleHotplug opens or releases the device as it is connected and
+// disconnected, and updates the availability topic accordingly.
+func (self *bridge) handleHotplug(ev device.HotplugEvent) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	switch ev {
+	case device.DeviceConnected:
+		if self.dev != nil {
+			return
+		}
+
+		dev, err := device.Open()
+		if err != nil {
+			log.Printf("Error opening the device: %v", err)
+			return
+		}
+		self.dev = dev
+
+		// Temporary light settings are lost when the device is
+		// unplugged, so set them again.
+		self.applyLightLocked()
+		self.applyProfileLocked()
+		self.publishAvailabilityLocked()
+
+		if device.Experimental() {
+			if p, err := dev.ActiveProfile(); err == nil {
+				self.setActiveProfileLocked(p)
+			} else {
+				log.Printf("Unable to read the active profile: %v", err)
+			}
+
+			var ctx context.Context
+			ctx, self.stopEvents = context.WithCancel(context.Background())
+			self.eventsDone = make(chan struct{})
+			go self.followEvents(ctx, dev, self.eventsDone)
+		}
+
+	case device.DeviceDisconnected:
+		if self.dev == nil {
+			return
+		}
+
+		dev := self.dev
+		self.dev = nil
+		self.publishAvailabilityLocked()
+
+		stop, done := self.stopEvents, self.eventsDone
+		self.stopEvents, self.eventsDone = nil, nil
+
+		// followEvents takes the lock to publish the profile, so it
+		// is released while waiting for it to return: the device
+		// can only be closed once nothing reads from it anymore.
+		self.mu.Unlock()
+		if stop != nil {
+			stop()
+			<-done
+		}
+		dev.Close()
+		self.mu.Lock()
+	}
+}
+
+// followEvents publishes the profile the mouse reports as active,
+// including when changed with the button on the mouse, until ctx is
+// cancelled. It closes done when it returns.
+func (self *bridge) followEvents(ctx context.Context, dev *device.Device, done chan<- struct{}) {
+	defer close(done)
+
+	events, err := dev.EventsWithState(ctx)
+	if err != nil {
+		log.Printf("Unable to follow the active profile: %v", err)
+		return
+	}
+
+	for ev := range events {
+		switch ev.Kind {
+		case device.ProfileChanged:
+			self.mu.Lock()
+			self.setActiveProfileLocked(ev.Profile)
+			self.mu.Unlock()
+
+		case device.ErrorEvent:
+			log.Print(ev.Error)
+		}
+	}
+}
+
+// setActiveProfileLocked records the profile reported by the mouse,
+// which is also the one to restore when it is plugged back in.
+func (self *bridge) setActiveProfileLocked(p device.ProfileID) {
+	if self.profileKnown && self.profile == p {
+		return
+	}
+
+	self.profile = p
+	self.profileKnown = true
+	self.publishProfileLocked()
+}
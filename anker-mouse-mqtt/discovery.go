@@ -0,0 +1,137 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Home Assistant MQTT discovery payloads. Only the fields used by the
+// bridge are described here; see
+// https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type haAvailability struct {
+	Topic string `json:"topic"`
+}
+
+type haLightConfig struct {
+	Name                string           `json:"name"`
+	UniqueId            string           `json:"unique_id"`
+	Schema              string           `json:"schema"`
+	CommandTopic        string           `json:"command_topic"`
+	StateTopic          string           `json:"state_topic"`
+	Brightness          bool             `json:"brightness"`
+	BrightnessScale     int              `json:"brightness_scale"`
+	SupportedColorModes []string         `json:"supported_color_modes"`
+	Effect              bool             `json:"effect"`
+	EffectList          []string         `json:"effect_list"`
+	Availability        []haAvailability `json:"availability"`
+	AvailabilityMode    string           `json:"availability_mode"`
+	Device              haDevice         `json:"device"`
+}
+
+type haSelectConfig struct {
+	Name             string           `json:"name"`
+	UniqueId         string           `json:"unique_id"`
+	CommandTopic     string           `json:"command_topic"`
+	StateTopic       string           `json:"state_topic"`
+	Options          []string         `json:"options"`
+	Availability     []haAvailability `json:"availability"`
+	AvailabilityMode string           `json:"availability_mode"`
+	Device           haDevice         `json:"device"`
+}
+
+// The light entity's effects map one to one onto the firmware's breath
+// speed values, with the first one being a steady light.
+var effectList = []string{"Steady", "Breathing 1", "Breathing 2", "Breathing 3"}
+
+var profileOptions = []string{"Profile 1", "Profile 2"}
+
+type discoveryMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+func (self *bridge) discoveryMessages() ([]discoveryMessage, error) {
+	dev := haDevice{
+		Identifiers:  []string{self.nodeId},
+		Name:         "Anker Gaming Mouse",
+		Manufacturer: "Anker",
+		Model:        "8200 DPI Programmable Gaming Mouse",
+	}
+
+	availability := []haAvailability{
+		{Topic: self.topic("bridge")},
+		{Topic: self.topic("availability")},
+	}
+
+	light := haLightConfig{
+		Name:                "Light",
+		UniqueId:            self.nodeId + "_light",
+		Schema:              "json",
+		CommandTopic:        self.topic("light/set"),
+		StateTopic:          self.topic("light"),
+		Brightness:          true,
+		BrightnessScale:     3,
+		SupportedColorModes: []string{"rgb"},
+		Effect:              true,
+		EffectList:          effectList,
+		Availability:        availability,
+		AvailabilityMode:    "all",
+		Device:              dev,
+	}
+
+	profile := haSelectConfig{
+		Name:             "Profile",
+		UniqueId:         self.nodeId + "_profile",
+		CommandTopic:     self.topic("profile/set"),
+		StateTopic:       self.topic("profile"),
+		Options:          profileOptions,
+		Availability:     availability,
+		AvailabilityMode: "all",
+		Device:           dev,
+	}
+
+	lightPayload, err := json.Marshal(light)
+	if err != nil {
+		return nil, err
+	}
+
+	profilePayload, err := json.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return []discoveryMessage{
+		{fmt.Sprintf("%s/light/%s/light/config", self.discoveryPrefix, self.nodeId), lightPayload},
+		{fmt.Sprintf("%s/select/%s/profile/config", self.discoveryPrefix, self.nodeId), profilePayload},
+	}, nil
+}
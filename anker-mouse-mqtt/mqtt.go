@@ -0,0 +1,101 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"flag"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	broker          = flag.String("broker", "tcp://localhost:1883", "URL of the MQTT broker.")
+	username        = flag.String("username", "", "Username to authenticate to the MQTT broker with.")
+	password        = flag.String("password", "", "Password to authenticate to the MQTT broker with.")
+	discoveryPrefix = flag.String("discovery_prefix", "homeassistant", "Home Assistant MQTT discovery prefix.")
+	topicPrefix     = flag.String("topic_prefix", "anker-mouse", "Prefix for the state and command topics.")
+	nodeId          = flag.String("node_id", "", "Unique identifier for this mouse in Home Assistant (defaults to one derived from the hostname).")
+)
+
+func defaultNodeId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Discovery topics only allow alphanumerics, underscores and dashes.
+	hostname = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, hostname)
+
+	return "anker_mouse_" + hostname
+}
+
+func main() {
+	flag.Parse()
+
+	if *nodeId == "" {
+		*nodeId = defaultNodeId()
+	}
+
+	b := newBridge(*discoveryPrefix, *topicPrefix, *nodeId)
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(*broker)
+	opts.SetClientID(*nodeId)
+	opts.SetUsername(*username)
+	opts.SetPassword(*password)
+	opts.SetAutoReconnect(true)
+	// Handlers publish and wait for the result, which requires
+	// them not to block the delivery of further messages.
+	opts.SetOrderMatters(false)
+	opts.SetWill(b.topic("bridge"), "offline", 1, true)
+	opts.SetOnConnectHandler(b.onConnect)
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		log.Printf("Connection to the MQTT broker lost: %v", err)
+	})
+
+	b.client = mqtt.NewClient(opts)
+	token := b.client.Connect()
+	token.Wait()
+	if err := token.Error(); err != nil {
+		log.Fatal(err)
+	}
+
+	events, err := device.Watch(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for ev := range events {
+		b.handleHotplug(ev)
+	}
+}
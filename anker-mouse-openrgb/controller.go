@@ -0,0 +1,235 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/flameeyes/anker-mouse-tool/device"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"log"
+	"sync"
+)
+
+type rgbColor struct {
+	R, G, B uint8
+}
+
+type mode struct {
+	name          string
+	flags         uint32
+	speedMin      uint32
+	speedMax      uint32
+	brightnessMin uint32
+	brightnessMax uint32
+	colorMode     uint32
+
+	speed      uint32
+	brightness uint32
+}
+
+const (
+	modeOff = iota
+	modeStatic
+	modeBreathing
+)
+
+// The mouse only has a single LED, whose brightness and breath speed
+// are both on a scale from 1 to 3 (0 turns the light off, or keeps it
+// steady, respectively).
+func newModes() []mode {
+	return []mode{
+		modeOff: {
+			name:      "Off",
+			colorMode: modeColorsNone,
+		},
+		modeStatic: {
+			name:          "Static",
+			flags:         modeFlagHasBrightness | modeFlagHasPerLEDColor,
+			brightnessMin: 1,
+			brightnessMax: 3,
+			colorMode:     modeColorsPerLED,
+			brightness:    2,
+		},
+		modeBreathing: {
+			name:          "Breathing",
+			flags:         modeFlagHasSpeed | modeFlagHasBrightness | modeFlagHasPerLEDColor,
+			speedMin:      1,
+			speedMax:      3,
+			brightnessMin: 1,
+			brightnessMax: 3,
+			colorMode:     modeColorsPerLED,
+			speed:         1,
+			brightness:    2,
+		},
+	}
+}
+
+// controller keeps the state advertised to OpenRGB clients, and
+// translates every change into a light setting on the mouse.
+type controller struct {
+	mu         sync.Mutex
+	dev        *device.Device
+	color      rgbColor
+	activeMode int
+	modes      []mode
+}
+
+func newController() *controller {
+	return &controller{
+		color:      rgbColor{0, 0, 255},
+		activeMode: modeStatic,
+		modes:      newModes(),
+	}
+}
+
+func (self *controller) data(protocol uint32) []byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	w := new(packetWriter)
+	w.i32(deviceTypeMouse)
+	w.str("Anker Gaming Mouse")
+	if protocol >= 1 {
+		w.str("Anker")
+	}
+	w.str("Anker 8200 DPI Programmable Gaming Mouse")
+	w.str("")
+	w.str("")
+	w.str("HID: anker-mouse-tool")
+
+	w.u16(uint16(len(self.modes)))
+	w.i32(int32(self.activeMode))
+	for i, m := range self.modes {
+		w.str(m.name)
+		w.i32(int32(i))
+		w.u32(m.flags)
+		w.u32(m.speedMin)
+		w.u32(m.speedMax)
+		if protocol >= 3 {
+			w.u32(m.brightnessMin)
+			w.u32(m.brightnessMax)
+		}
+		w.u32(0) // colors_min
+		w.u32(0) // colors_max
+		w.u32(m.speed)
+		if protocol >= 3 {
+			w.u32(m.brightness)
+		}
+		w.u32(0) // direction
+		w.u32(m.colorMode)
+		w.u16(0) // no mode-specific colors
+	}
+
+	w.u16(1)
+	w.str("Mouse")
+	w.i32(zoneTypeSingle)
+	w.u32(1) // leds_min
+	w.u32(1) // leds_max
+	w.u32(1) // leds_count
+	w.u16(0) // no matrix map
+
+	w.u16(1)
+	w.str("Logo")
+	w.u32(0)
+
+	w.u16(1)
+	w.color(self.color)
+
+	out := new(packetWriter)
+	out.u32(uint32(w.Len() + 4))
+	out.Write(w.Bytes())
+	return out.Bytes()
+}
+
+func clamp(v, min, max uint32) uint32 {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	}
+	return v
+}
+
+func (self *controller) updateMode(idx int, speed, brightness uint32, hasBrightness bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if idx < 0 || idx >= len(self.modes) {
+		log.Printf("Ignoring update for unknown mode %v", idx)
+		return
+	}
+
+	m := &self.modes[idx]
+	if m.flags&modeFlagHasSpeed != 0 {
+		m.speed = clamp(speed, m.speedMin, m.speedMax)
+	}
+	if hasBrightness && m.flags&modeFlagHasBrightness != 0 {
+		m.brightness = clamp(brightness, m.brightnessMin, m.brightnessMax)
+	}
+
+	self.activeMode = idx
+	self.applyLocked()
+}
+
+func (self *controller) setCustomMode() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.activeMode = modeStatic
+	self.applyLocked()
+}
+
+func (self *controller) setColor(c rgbColor) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.color = c
+	self.applyLocked()
+}
+
+func (self *controller) applyLocked() {
+	if self.dev == nil {
+		dev, err := device.Open()
+		if err != nil {
+			log.Printf("Error opening the device: %v", err)
+			return
+		}
+		self.dev = dev
+	}
+
+	m := self.modes[self.activeMode]
+	c := colorful.Color{
+		R: float64(self.color.R) / 255.0,
+		G: float64(self.color.G) / 255.0,
+		B: float64(self.color.B) / 255.0,
+	}
+
+	err := self.dev.SetLight(c, device.Brightness(m.brightness), device.BreathSpeed(m.speed))
+	if err != nil {
+		// The mouse might have been unplugged; try to open it again
+		// at the next update.
+		log.Printf("Error setting light: %v", err)
+		self.dev.Close()
+		self.dev = nil
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"net"
+)
+
+var (
+	listen = flag.String("listen", "localhost:6742", "Address to listen on for OpenRGB SDK clients.")
+)
+
+type client struct {
+	conn     net.Conn
+	ctrl     *controller
+	name     string
+	protocol uint32
+}
+
+func (self *client) reply(deviceIndex, packetId uint32, data []byte) error {
+	return writePacket(self.conn, deviceIndex, packetId, data)
+}
+
+func (self *client) handlePacket(hdr *packetHeader, data []byte) error {
+	r := newPacketReader(data)
+
+	switch hdr.PacketId {
+	case packetRequestControllerCount:
+		w := new(packetWriter)
+		w.u32(1)
+		return self.reply(0, hdr.PacketId, w.Bytes())
+
+	case packetRequestControllerData:
+		if hdr.DeviceIndex != 0 {
+			return nil
+		}
+		protocol := uint32(0)
+		if len(data) >= 4 {
+			protocol = r.u32()
+		}
+		if protocol > maxProtocolVersion {
+			protocol = maxProtocolVersion
+		}
+		return self.reply(0, hdr.PacketId, self.ctrl.data(protocol))
+
+	case packetRequestProtocolVersion:
+		protocol := r.u32()
+		if protocol > maxProtocolVersion {
+			protocol = maxProtocolVersion
+		}
+		self.protocol = protocol
+
+		w := new(packetWriter)
+		w.u32(maxProtocolVersion)
+		return self.reply(0, hdr.PacketId, w.Bytes())
+
+	case packetSetClientName:
+		self.name = r.str()
+		log.Printf("Client %v identified as %q", self.conn.RemoteAddr(), self.name)
+
+	case packetRequestProfileList:
+		// Profiles are not supported, so always answer with an
+		// empty list.
+		w := new(packetWriter)
+		w.u32(6)
+		w.u16(0)
+		return self.reply(0, hdr.PacketId, w.Bytes())
+
+	case packetUpdateLEDs:
+		r.u32() // data_size
+		if colors := r.colors(); len(colors) > 0 {
+			self.ctrl.setColor(colors[0])
+		}
+
+	case packetUpdateZoneLEDs:
+		r.u32() // data_size
+		r.u32() // zone_idx
+		if colors := r.colors(); len(colors) > 0 {
+			self.ctrl.setColor(colors[0])
+		}
+
+	case packetUpdateSingleLED:
+		r.i32() // led_idx
+		c := r.color()
+		if r.err == nil {
+			self.ctrl.setColor(c)
+		}
+
+	case packetSetCustomMode:
+		self.ctrl.setCustomMode()
+
+	case packetUpdateMode, packetSaveMode:
+		// Saving to the device profile is not supported (the modes
+		// are not flagged as such), so saving is the same as updating.
+		r.u32() // data_size
+		idx := r.i32()
+		r.str() // name
+		r.i32() // value
+		r.u32() // flags
+		r.u32() // speed_min
+		r.u32() // speed_max
+		if self.protocol >= 3 {
+			r.u32() // brightness_min
+			r.u32() // brightness_max
+		}
+		r.u32() // colors_min
+		r.u32() // colors_max
+		speed := r.u32()
+		var brightness uint32
+		if self.protocol >= 3 {
+			brightness = r.u32()
+		}
+		if r.err != nil {
+			log.Printf("Invalid mode update from %v: %v", self.conn.RemoteAddr(), r.err)
+			return nil
+		}
+		self.ctrl.updateMode(int(idx), speed, brightness, self.protocol >= 3)
+
+	default:
+		log.Printf("Ignoring unsupported packet %v from %v", hdr.PacketId, self.conn.RemoteAddr())
+	}
+
+	return nil
+}
+
+func (self *client) serve() {
+	defer self.conn.Close()
+
+	br := bufio.NewReader(self.conn)
+	for {
+		hdr, data, err := readPacket(br)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading from %v: %v", self.conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if err := self.handlePacket(hdr, data); err != nil {
+			log.Printf("Error replying to %v: %v", self.conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctrl := newController()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		c := &client{
+			conn: conn,
+			ctrl: ctrl,
+		}
+		go c.serve()
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Implementation of the OpenRGB SDK network protocol, up to protocol
+// version 3. See
+// https://gitlab.com/CalcProgrammer1/OpenRGB/-/wikis/OpenRGB-SDK-Documentation
+
+const maxProtocolVersion = 3
+
+var packetMagic = [4]byte{'O', 'R', 'G', 'B'}
+
+const (
+	packetRequestControllerCount = 0
+	packetRequestControllerData  = 1
+	packetRequestProtocolVersion = 40
+	packetSetClientName          = 50
+	packetRequestProfileList     = 150
+	packetUpdateLEDs             = 1050
+	packetUpdateZoneLEDs         = 1051
+	packetUpdateSingleLED        = 1052
+	packetSetCustomMode          = 1100
+	packetUpdateMode             = 1101
+	packetSaveMode               = 1102
+)
+
+const (
+	deviceTypeMouse = 6
+
+	zoneTypeSingle = 0
+
+	modeFlagHasSpeed       = 1 << 0
+	modeFlagHasBrightness  = 1 << 4
+	modeFlagHasPerLEDColor = 1 << 5
+
+	modeColorsNone   = 0
+	modeColorsPerLED = 1
+)
+
+type packetHeader struct {
+	Magic       [4]byte
+	DeviceIndex uint32
+	PacketId    uint32
+	PacketSize  uint32
+}
+
+func readPacket(r io.Reader) (*packetHeader, []byte, error) {
+	var hdr packetHeader
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, nil, err
+	}
+
+	if hdr.Magic != packetMagic {
+		return nil, nil, fmt.Errorf("Invalid packet magic: %v", hdr.Magic)
+	}
+
+	// Nothing this server accepts comes close to this, so it is only
+	// there to avoid allocating whatever a broken client asks for.
+	if hdr.PacketSize > 1<<20 {
+		return nil, nil, fmt.Errorf("Packet too big: %v bytes", hdr.PacketSize)
+	}
+
+	data := make([]byte, hdr.PacketSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, nil, err
+	}
+
+	return &hdr, data, nil
+}
+
+func writePacket(w io.Writer, deviceIndex, packetId uint32, data []byte) error {
+	hdr := packetHeader{
+		Magic:       packetMagic,
+		DeviceIndex: deviceIndex,
+		PacketId:    packetId,
+		PacketSize:  uint32(len(data)),
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, hdr)
+	buf.Write(data)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// packetWriter builds the payload of a packet. Writes to a bytes.Buffer
+// cannot fail, so errors are not reported.
+type packetWriter struct {
+	bytes.Buffer
+}
+
+func (self *packetWriter) u16(v uint16) {
+	binary.Write(&self.Buffer, binary.LittleEndian, v)
+}
+
+func (self *packetWriter) u32(v uint32) {
+	binary.Write(&self.Buffer, binary.LittleEndian, v)
+}
+
+func (self *packetWriter) i32(v int32) {
+	binary.Write(&self.Buffer, binary.LittleEndian, v)
+}
+
+func (self *packetWriter) str(s string) {
+	self.u16(uint16(len(s) + 1))
+	self.WriteString(s)
+	self.WriteByte(0)
+}
+
+func (self *packetWriter) color(c rgbColor) {
+	self.Write([]byte{c.R, c.G, c.B, 0})
+}
+
+// packetReader decodes the payload of a packet, remembering the first
+// error encountered.
+type packetReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func newPacketReader(data []byte) *packetReader {
+	return &packetReader{r: bytes.NewReader(data)}
+}
+
+func (self *packetReader) read(v interface{}) {
+	if self.err != nil {
+		return
+	}
+	self.err = binary.Read(self.r, binary.LittleEndian, v)
+}
+
+func (self *packetReader) u16() uint16 {
+	var v uint16
+	self.read(&v)
+	return v
+}
+
+func (self *packetReader) u32() uint32 {
+	var v uint32
+	self.read(&v)
+	return v
+}
+
+func (self *packetReader) i32() int32 {
+	var v int32
+	self.read(&v)
+	return v
+}
+
+func (self *packetReader) str() string {
+	b := make([]byte, self.u16())
+	self.read(b)
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+func (self *packetReader) color() rgbColor {
+	var b [4]byte
+	self.read(&b)
+	return rgbColor{b[0], b[1], b[2]}
+}
+
+func (self *packetReader) colors() []rgbColor {
+	n := self.u16()
+	if self.err != nil {
+		return nil
+	}
+
+	colors := make([]rgbColor, 0, n)
+	for i := uint16(0); i < n && self.err == nil; i++ {
+		colors = append(colors, self.color())
+	}
+	return colors
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+)
+
+var (
+	set      = flag.Int("set", 0, "Set the polling rate in Hz (125, 250, 500 or 1000), rather than showing it.")
+	defaults = flag.Bool("defaults", false, "If no configuration was recorded by these tools, write the default one, resetting both profiles.")
+	force    = flag.Bool("force", false, "Write the configuration even if it could lock the user out (e.g. no button bound to left click).")
+)
+
+func main() {
+	flag.Parse()
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *set == 0 {
+		rate, err := dev.PollingRate()
+		if err != nil {
+			log.Fatalf("Unable to read the polling rate: %v", err)
+		}
+
+		fmt.Println(rate)
+		return
+	}
+
+	rate, err := device.NewPollingRate(*set)
+	if err != nil {
+		log.Fatalf("Invalid value for -set: %v", err)
+	}
+
+	// The polling rate is only written together with the rest of the
+	// configuration, so start from the last one written.
+	cfg, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		if !*defaults {
+			log.Fatal("No configuration was written by these tools before, so setting the polling rate would reset both profiles to the defaults; pass -defaults to do so anyway.")
+		}
+		log.Print("No configuration was written by these tools before; writing the default configuration.")
+		cfg = device.NewConfig()
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	cfg.PollingRate = rate
+
+	write := cfg.Write
+	if *force {
+		write = cfg.WriteForce
+	}
+
+	if err := write(dev); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.RecordConfig(cfg); err != nil {
+		log.Fatalf("Configuration written, but not recorded: %v", err)
+	}
+}
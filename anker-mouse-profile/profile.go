@@ -23,20 +23,124 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/focus"
 	"log"
 )
 
 var (
-	profile = flag.Int("profile", 1, "Select profile 1 or 2 of the mouse.")
+	profile   = flag.Int("profile", 1, "Select profile 1 or 2 of the mouse.")
+	rulesFile = flag.String("rules", "", "Keep running, and switch profile depending on the focused window according to the rules in this JSON file.")
+	next      = flag.Bool("next", false, "Switch to the profile after the active one, as the profile button does.")
+	toggle    = flag.Bool("toggle", false, "Switch to the profile that is not active; the same as -next, as there are only two profiles.")
+	status    = flag.Bool("status", false, "Only show the active profile and DPI stage.")
 )
 
+func showStatus(dev *device.Device) {
+	p, err := dev.ActiveProfile()
+	if err != nil {
+		log.Fatalf("Unable to read the active profile: %v", err)
+	}
+
+	s, err := dev.ActiveDPIStage()
+	if err != nil {
+		log.Fatalf("Unable to read the active DPI stage: %v", err)
+	}
+
+	fmt.Printf("profile %v dpi_stage %v\n", p, s)
+}
+
+// profileSetter is the part of device.Device used by watchFocus.
+type profileSetter interface {
+	SetProfile(profile device.ProfileID) error
+	Close()
+}
+
+// sameWindow reports whether two focus changes are for the same window,
+// e.g. only its title changed.
+func sameWindow(a, b focus.Window) bool {
+	return a.Class == b.Class && a.Pid == b.Pid
+}
+
+// watchFocus switches profile according to the rules every time the
+// focused window changes, until src stops reporting windows. The
+// device is opened when needed, and opened again after a write fails,
+// e.g. because the mouse was unplugged.
+//
+// The profile is only skipped when the same window, e.g. with a new
+// title, maps to the profile written last: the profile button on the
+// mouse might have changed the active profile since the focus moved.
+func watchFocus(ctx context.Context, open func() (profileSetter, error), r *rules, src focus.Source) error {
+	windows, err := src.Windows(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dev profileSetter
+	defer func() {
+		if dev != nil {
+			dev.Close()
+		}
+	}()
+
+	var current device.ProfileID // Written last, if known.
+	var last focus.Window
+	for w := range windows {
+		same := sameWindow(w, last)
+		last = w
+
+		p := r.match(w)
+		if p == 0 || (p == current && same) {
+			continue
+		}
+
+		if dev == nil {
+			if dev, err = open(); err != nil {
+				log.Printf("Error opening the device: %v", err)
+				dev, current = nil, 0
+				continue
+			}
+		}
+
+		log.Printf("Switching to profile %v for %q (%v)", p, w.Title, w.Class)
+		if err := dev.SetProfile(p); err != nil {
+			// The mouse might have been unplugged; open it again for
+			// the next switch.
+			log.Printf("Error switching profile: %v", err)
+			dev.Close()
+			dev, current = nil, 0
+			continue
+		}
+		current = p
+	}
+
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
-	if *profile < 1 || *profile > 2 {
-		log.Fatalf("Invalid value for -profile: %v", *profile)
+	if *rulesFile != "" {
+		r, err := loadRules(*rulesFile)
+		if err != nil {
+			log.Fatalf("Invalid rules file %v: %v", *rulesFile, err)
+		}
+
+		src, err := focus.Detect()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		open := func() (profileSetter, error) {
+			return device.Open()
+		}
+		if err := watchFocus(context.Background(), open, r, src); err != nil {
+			log.Fatal(err)
+		}
+		log.Fatal("Lost connection to the desktop session")
 	}
 
 	dev, err := device.Open()
@@ -44,8 +148,26 @@ func main() {
 		log.Fatal(err)
 	}
 
-	profileId := byte(*profile) - 1
-	err = dev.SetProfile(profileId)
+	if *status {
+		showStatus(dev)
+		return
+	}
+
+	var p device.ProfileID
+	if *next || *toggle {
+		active, err := dev.ActiveProfile()
+		if err != nil {
+			log.Fatalf("Unable to read the active profile: %v", err)
+		}
+		p = active.Next()
+	} else {
+		p, err = device.NewProfileID(*profile)
+		if err != nil {
+			log.Fatalf("Invalid value for -profile: %v", err)
+		}
+	}
+
+	err = dev.SetProfile(p)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,154 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/focus"
+	"reflect"
+	"testing"
+)
+
+type fakeSetter struct {
+	profiles []device.ProfileID
+	fail     int // Fail this many writes.
+	closed   int
+}
+
+func (self *fakeSetter) SetProfile(profile device.ProfileID) error {
+	self.profiles = append(self.profiles, profile)
+	if self.fail > 0 {
+		self.fail--
+		return errors.New("Device busy")
+	}
+
+	return nil
+}
+
+func (self *fakeSetter) Close() {
+	self.closed++
+}
+
+func TestWatchFocus(t *testing.T) {
+	r, err := parseRules([]byte(`{"rules": [{"class": "FreeCAD", "profile": 2}, {"class": "xterm", "profile": 1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Windows matching no rule leave the profile alone; they are also
+	// focused last, as Fake.Focus only returns once the window before
+	// has been received.
+	unmatched := focus.Window{Class: "unmatched"}
+
+	tests := []struct {
+		name      string
+		windows   []focus.Window
+		fail      int
+		failOpens int
+		want      []device.ProfileID
+		wantOpens int
+	}{
+		{
+			name:      "switches on match",
+			windows:   []focus.Window{{Class: "FreeCAD"}, {Class: "xterm"}},
+			want:      []device.ProfileID{2, 1},
+			wantOpens: 1,
+		},
+		{
+			name:      "title changes",
+			windows:   []focus.Window{{Class: "FreeCAD"}, {Class: "FreeCAD", Title: "Other"}, {Class: "FreeCAD", Title: "Third"}},
+			want:      []device.ProfileID{2},
+			wantOpens: 1,
+		},
+		{
+			// The profile button might have been pressed in between.
+			name:      "same profile in another window",
+			windows:   []focus.Window{{Class: "FreeCAD", Pid: 1}, {Class: "FreeCAD", Pid: 2}, unmatched, {Class: "FreeCAD", Pid: 2}},
+			want:      []device.ProfileID{2, 2, 2},
+			wantOpens: 1,
+		},
+		{
+			name:    "no match",
+			windows: []focus.Window{unmatched, {Class: "xterm"}, unmatched},
+			want:    []device.ProfileID{1},
+			// Opened only when needed.
+			wantOpens: 1,
+		},
+		{
+			name:      "reopens after errors",
+			windows:   []focus.Window{{Class: "FreeCAD"}, {Class: "FreeCAD", Title: "Other"}, {Class: "FreeCAD", Title: "Third"}},
+			fail:      1,
+			want:      []device.ProfileID{2, 2},
+			wantOpens: 2,
+		},
+		{
+			name:      "retries opening",
+			windows:   []focus.Window{{Class: "FreeCAD"}, {Class: "FreeCAD", Title: "Other"}},
+			failOpens: 1,
+			want:      []device.ProfileID{2},
+			wantOpens: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			src := focus.NewFake()
+			dev := &fakeSetter{fail: tt.fail}
+
+			opens := 0
+			open := func() (profileSetter, error) {
+				opens++
+				if opens <= tt.failOpens {
+					return nil, errors.New("No such device")
+				}
+				return dev, nil
+			}
+
+			done := make(chan error)
+			go func() {
+				done <- watchFocus(ctx, open, r, src)
+			}()
+
+			for _, w := range append(tt.windows, unmatched, unmatched) {
+				src.Focus(w)
+			}
+			cancel()
+
+			if err := <-done; err != nil {
+				t.Fatalf("watchFocus() = %v", err)
+			}
+			if !reflect.DeepEqual(dev.profiles, tt.want) {
+				t.Errorf("Profiles set %v, want %v", dev.profiles, tt.want)
+			}
+			if opens != tt.wantOpens {
+				t.Errorf("Device opened %v times, want %v", opens, tt.wantOpens)
+			}
+			if successful := opens - tt.failOpens; dev.closed != successful {
+				t.Errorf("Device closed %v times, opened %v", dev.closed, successful)
+			}
+		})
+	}
+}
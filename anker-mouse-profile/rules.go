@@ -0,0 +1,138 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/focus"
+	"os"
+	"regexp"
+)
+
+// rule selects a profile when all of its non-empty patterns match the
+// focused window. Patterns are regular expressions that have to match
+// the whole value.
+type rule struct {
+	Class      string           `json:"class"`
+	Title      string           `json:"title"`
+	Executable string           `json:"executable"`
+	Profile    device.ProfileID `json:"profile"`
+
+	class, title, executable *regexp.Regexp
+}
+
+// rules is the format of the -rules file, e.g.:
+//
+//	{
+//	  "default": 1,
+//	  "rules": [
+//	    {"class": "FreeCAD|librecad", "profile": 2},
+//	    {"executable": ".*/blender", "profile": 2}
+//	  ]
+//	}
+//
+// The first matching rule wins. If none match, the default profile is
+// selected, or the profile is left alone if there is no default.
+type rules struct {
+	Default device.ProfileID `json:"default"`
+	Rules   []*rule          `json:"rules"`
+}
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if p == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + p + ")$")
+}
+
+func loadRules(path string) (*rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRules(data)
+}
+
+func parseRules(data []byte) (*rules, error) {
+	var r rules
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Default != 0 && !r.Default.Valid() {
+		return nil, fmt.Errorf("Invalid default profile: %v", r.Default)
+	}
+
+	for i, rl := range r.Rules {
+		if !rl.Profile.Valid() {
+			return nil, fmt.Errorf("Invalid profile for rule %v: %v", i, rl.Profile)
+		}
+
+		if rl.class, err = compilePattern(rl.Class); err != nil {
+			return nil, fmt.Errorf("Invalid class pattern for rule %v: %v", i, err)
+		}
+		if rl.title, err = compilePattern(rl.Title); err != nil {
+			return nil, fmt.Errorf("Invalid title pattern for rule %v: %v", i, err)
+		}
+		if rl.executable, err = compilePattern(rl.Executable); err != nil {
+			return nil, fmt.Errorf("Invalid executable pattern for rule %v: %v", i, err)
+		}
+
+		if rl.class == nil && rl.title == nil && rl.executable == nil {
+			return nil, fmt.Errorf("Rule %v has no patterns", i)
+		}
+	}
+
+	return &r, nil
+}
+
+func (self *rule) matches(w focus.Window) bool {
+	if self.class != nil && !self.class.MatchString(w.Class) {
+		return false
+	}
+	if self.title != nil && !self.title.MatchString(w.Title) {
+		return false
+	}
+	if self.executable != nil && !self.executable.MatchString(w.Executable) {
+		return false
+	}
+
+	return true
+}
+
+// match returns the profile to select for the window, or 0 if the
+// profile should not be changed.
+func (self *rules) match(w focus.Window) device.ProfileID {
+	for _, rl := range self.Rules {
+		if rl.matches(w) {
+			return rl.Profile
+		}
+	}
+
+	return self.Default
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/focus"
+	"strings"
+	"testing"
+)
+
+const testRules = `{
+  "default": 1,
+  "rules": [
+    {"class": "FreeCAD|librecad", "profile": 2},
+    {"class": "firefox", "title": ".*Figma.*", "profile": 2},
+    {"executable": ".*/blender", "profile": 2}
+  ]
+}`
+
+func TestRulesMatch(t *testing.T) {
+	r, err := parseRules([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		window focus.Window
+		want   device.ProfileID
+	}{
+		{"class", focus.Window{Class: "FreeCAD", Title: "Unnamed"}, 2},
+		{"class alternative", focus.Window{Class: "librecad"}, 2},
+		{"class is anchored", focus.Window{Class: "FreeCAD-daily"}, 1},
+		{"class and title", focus.Window{Class: "firefox", Title: "Mouse case - Figma"}, 2},
+		{"class without title", focus.Window{Class: "firefox", Title: "Mozilla Firefox"}, 1},
+		{"title without class", focus.Window{Class: "chromium", Title: "Mouse case - Figma"}, 1},
+		{"executable", focus.Window{Class: "Blender", Executable: "/usr/bin/blender"}, 2},
+		{"executable is anchored", focus.Window{Executable: "/usr/bin/blender-thumbnailer"}, 1},
+		{"no information", focus.Window{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.match(tt.window); got != tt.want {
+				t.Errorf("match(%+v) = %v, want %v", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesWithoutDefault(t *testing.T) {
+	r, err := parseRules([]byte(`{"rules": [{"class": "FreeCAD", "profile": 2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.match(focus.Window{Class: "xterm"}); got != 0 {
+		t.Errorf("match() = %v, want 0 to leave the profile alone", got)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{"invalid default", `{"default": 3, "rules": []}`, "Invalid default profile"},
+		{"invalid profile", `{"rules": [{"class": "a", "profile": 0}]}`, "Invalid profile for rule 0"},
+		{"invalid pattern", `{"rules": [{"class": "a", "profile": 1}, {"title": "(", "profile": 2}]}`, "Invalid title pattern for rule 1"},
+		{"no patterns", `{"rules": [{"profile": 1}]}`, "Rule 0 has no patterns"},
+		{"invalid JSON", `{"rules": `, "unexpected end of JSON input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRules([]byte(tt.rules))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseRules() = %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
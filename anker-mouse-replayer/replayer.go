@@ -25,9 +25,10 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/GeertJohan/go.hid"
+	"github.com/flameeyes/anker-mouse-tool/device"
 	colorful "github.com/lucasb-eyer/go-colorful"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -38,14 +39,15 @@ var (
 
 	profile1DPI = flag.String("profile1_dpi", "1000,2000,4000,8200", "Comma-separated list of DPI values. Separate X:Y values with a colon for split-DPI; give an empty value to disable that DPI level (e.g. 1000:800,2000:1600,,).")
 	profile2DPI = flag.String("profile2_dpi", "1000,2000,4000,8200", "Comma-separated list of DPI values. Separate X:Y values with a colon for split-DPI; give an empty value to disable that DPI level (e.g. 1000:800,2000:1600,,).")
-)
 
-const (
-	HoltekVendorId     = 0x04d9
-	AnkerMouseDeviceId = 0xfa50
+	pollingRate = flag.Int("polling_rate", 0, "USB polling rate in Hz: 125, 250, 500 or 1000 (default unchanged; only 500 without "+device.ExperimentalEnv+").")
+
+	force = flag.Bool("force", false, "Write the configuration even if it could lock the user out (e.g. no button bound to left click).")
+
+	session = flag.String("session", "", "Send the reports recorded in this session file (see ANKER_MOUSE_SESSION) instead of the configuration from the flags.")
 )
 
-func parseLightFlag(v string) (*colorful.Color, byte, byte, error) {
+func parseLightFlag(v string) (*colorful.Color, device.Brightness, device.BreathSpeed, error) {
 	p := strings.Split(v, ":")
 	if len(p) != 3 {
 		return nil, 0, 0, fmt.Errorf("Invalid profile light setting: %v", v)
@@ -61,21 +63,40 @@ func parseLightFlag(v string) (*colorful.Color, byte, byte, error) {
 		return nil, 0, 0, err
 	}
 
+	b, err := device.NewBrightness(bright)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
 	breath, err := strconv.Atoi(p[2])
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
-	return &c, byte(bright), byte(breath), nil
+	s, err := device.NewBreathSpeed(breath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return &c, b, s, nil
+}
+
+func parseDPI(v string) (device.DPI, error) {
+	dpi, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return device.NewDPI(dpi)
 }
 
-func parseDPIFlag(v string) (*[4][2]int, error) {
+func parseDPIFlag(v string) (*[4][2]device.DPI, error) {
 	p := strings.Split(v, ",")
 	if len(p) != 4 {
 		return nil, fmt.Errorf("Not enough DPI setting: %v", v)
 	}
 
-	var dpi = [4][2]int{}
+	var dpi = [4][2]device.DPI{}
 	for i := range p {
 		if p[i] == "" {
 			dpi[i][0] = 0
@@ -83,22 +104,22 @@ func parseDPIFlag(v string) (*[4][2]int, error) {
 			xy := strings.Split(p[i], ":")
 			switch len(xy) {
 			case 1:
-				xydpi, err := strconv.Atoi(xy[0])
+				xydpi, err := parseDPI(xy[0])
 				if err != nil {
 					return nil, err
 				}
-				dpi[i] = [2]int{xydpi, xydpi}
+				dpi[i] = [2]device.DPI{xydpi, xydpi}
 			case 2:
-				xdpi, err := strconv.Atoi(xy[0])
+				xdpi, err := parseDPI(xy[0])
 				if err != nil {
 					return nil, err
 				}
-				ydpi, err := strconv.Atoi(xy[1])
+				ydpi, err := parseDPI(xy[1])
 				if err != nil {
 					return nil, err
 				}
 
-				dpi[i] = [2]int{xdpi, ydpi}
+				dpi[i] = [2]device.DPI{xdpi, ydpi}
 			default:
 				return nil, fmt.Errorf("Invalid format for DPI flag: %v", v)
 			}
@@ -108,9 +129,48 @@ func parseDPIFlag(v string) (*[4][2]int, error) {
 	return &dpi, nil
 }
 
+// replaySession sends the reports recorded in a session file. The
+// button configurations in it cannot be checked, so they are only
+// sent with -force.
+func replaySession(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	reports, err := device.ReadSession(f)
+	if err != nil {
+		log.Fatalf("Invalid session %v: %v", path, err)
+	}
+
+	if !*force {
+		for _, r := range reports {
+			if r.Direction == device.ReportSent && r.Type == "buttons_profile" {
+				log.Fatal("The session writes the button configuration, which could lock the user out; pass -force to replay it anyway.")
+			}
+		}
+	}
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+
+	if err := dev.Replay(reports, nil); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func main() {
 	flag.Parse()
 
+	if *session != "" {
+		replaySession(*session)
+		return
+	}
+
 	c1, bright1, breath1, err := parseLightFlag(*profile1Light)
 	if err != nil {
 		log.Fatalf("Invalid value for -profile1_light: %v", err)
@@ -131,23 +191,45 @@ func main() {
 		log.Fatalf("Invalid value for -profile2_dpi: %v", err)
 	}
 
-	device, err := hid.Open(HoltekVendorId, AnkerMouseDeviceId, "")
+	var rate device.PollingRate
+	if *pollingRate != 0 {
+		rate, err = device.NewPollingRate(*pollingRate)
+		if err != nil {
+			log.Fatalf("Invalid value for -polling_rate: %v", err)
+		}
+	}
+
+	dev, err := device.Open()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cfg := NewConfig()
+	cfg := device.NewConfig()
+	cfg.PollingRate = rate
 	cfg.Profiles[0].LightProfile.SetColor(*c1)
 	cfg.Profiles[0].LightProfile.Brightness = bright1
 	cfg.Profiles[0].LightProfile.BreathSpeed = breath1
-	cfg.Profiles[0].DPIProfile.SetDPI(*dpi1)
+	if err := cfg.Profiles[0].DPIProfile.SetDPI(*dpi1); err != nil {
+		log.Fatalf("Invalid value for -profile1_dpi: %v", err)
+	}
 	cfg.Profiles[1].LightProfile.SetColor(*c2)
 	cfg.Profiles[1].LightProfile.Brightness = bright2
 	cfg.Profiles[1].LightProfile.BreathSpeed = breath2
-	cfg.Profiles[1].DPIProfile.SetDPI(*dpi2)
+	if err := cfg.Profiles[1].DPIProfile.SetDPI(*dpi2); err != nil {
+		log.Fatalf("Invalid value for -profile2_dpi: %v", err)
+	}
+
+	write := cfg.Write
+	if *force {
+		write = cfg.WriteForce
+	}
 
-	err = cfg.Write(device)
+	err = write(dev)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if err := device.RecordConfig(cfg); err != nil {
+		log.Fatalf("Configuration written, but not recorded: %v", err)
+	}
 }
@@ -0,0 +1,123 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	profile = flag.Int("profile", 0, "Reset only profile 1 or 2 of the mouse (default both).")
+	yes     = flag.Bool("yes", false, "Do not ask for confirmation.")
+)
+
+// backup saves a copy of the last configuration written by the tools,
+// and returns its path. This is not necessarily what the mouse has now:
+// the configuration cannot be read back from it, and the vendor tool
+// does not record what it writes.
+func backup(cfg *device.Config) (string, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := device.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("backup-%v.json", time.Now().Format("20060102-150405")))
+	return path, os.WriteFile(path, data, 0644)
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%v [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+func main() {
+	flag.Parse()
+
+	var profiles []device.ProfileID
+	what := "both profiles"
+	if *profile != 0 {
+		p, err := device.NewProfileID(*profile)
+		if err != nil {
+			log.Fatalf("Invalid value for -profile: %v", err)
+		}
+		profiles = append(profiles, p)
+		what = fmt.Sprintf("profile %v", p)
+	}
+
+	last, err := device.LoadLastConfig()
+	if os.IsNotExist(err) {
+		last = nil
+	} else if err != nil {
+		log.Fatalf("Unable to load the last configuration written by these tools: %v", err)
+	}
+
+	question := fmt.Sprintf("Reset %v of the mouse to the factory defaults?", what)
+	if last != nil {
+		question += " The last configuration written by these tools (which may not be the current one, if anything else changed it since) will be backed up first."
+	} else {
+		question += " No configuration was written by these tools before, and the current one cannot be read back from the mouse, so nothing will be backed up."
+	}
+
+	if !*yes && !confirm(question) {
+		log.Fatal("Aborted.")
+	}
+
+	if last != nil {
+		path, err := backup(last)
+		if err != nil {
+			log.Fatalf("Unable to back up the last configuration written by these tools: %v", err)
+		}
+		log.Printf("Last configuration written by these tools backed up to %v", path)
+	}
+
+	dev, err := device.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := dev.Reset(profiles...); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.RecordConfig(device.NewConfig(), profiles...); err != nil {
+		log.Fatalf("Configuration reset, but not recorded: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/virtualmouse"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	stateFile = flag.String("state", "", "Write the state of the virtual mouse on exit to this file as JSON, rather than to the standard output.")
+	backend   = flag.String("backend", "hidraw", "Backend for the command to use, unless "+device.BackendEnv+" is already set.")
+	quiet     = flag.Bool("quiet", false, "Do not log the feature reports received.")
+
+	descriptors = flag.String("descriptors", os.Getenv(virtualmouse.DescriptorsEnv), "Directory with the report descriptors dumped from the real mouse by anker-mouse-doctor -dump_descriptors (default from "+virtualmouse.DescriptorsEnv+"); without it, the ones checked in with the virtualmouse package are used, or reconstructed ones if there are none.")
+)
+
+// logReport logs a feature report received, with the first bytes of
+// its content.
+func logReport(data []byte) {
+	shown := data
+	if len(shown) > 16 {
+		shown = shown[:16]
+	}
+
+	log.Printf("Received %v report (%v bytes): % x", device.ReportType(data), len(data), shown)
+}
+
+func writeState(m *virtualmouse.Model) {
+	data, err := json.MarshalIndent(m.State(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if *stateFile == "" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := os.WriteFile(*stateFile, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command with the virtual mouse connected, and returns
+// its exit status.
+func run(args []string) int {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv(device.BackendEnv) == "" {
+		cmd.Env = append(cmd.Env, device.BackendEnv+"="+*backend)
+	}
+
+	err := cmd.Run()
+
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.ExitCode()
+	case err != nil:
+		log.Print(err)
+		return 1
+	}
+
+	return 0
+}
+
+func main() {
+	flag.Parse()
+
+	m := virtualmouse.NewModel()
+	if !*quiet {
+		m.OnReport = logReport
+	}
+
+	var descs virtualmouse.Descriptors
+	var err error
+	if *descriptors != "" {
+		descs, err = virtualmouse.LoadDescriptors(*descriptors)
+	} else {
+		var dumped bool
+		descs, dumped, err = virtualmouse.DefaultDescriptors()
+		if !dumped {
+			log.Print("Using reconstructed report descriptors, which might differ from the mouse's; see -descriptors.")
+		}
+	}
+	if err != nil {
+		log.Fatalf("Unable to load the report descriptors: %v", err)
+	}
+
+	mouse, err := virtualmouse.Start(m, descs)
+	if err != nil {
+		log.Fatalf("Unable to create the virtual mouse: %v", err)
+	}
+
+	status := 0
+	if flag.NArg() > 0 {
+		status = run(flag.Args())
+	} else {
+		log.Print("Virtual mouse connected; interrupt to remove it.")
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+	}
+
+	mouse.Close()
+	writeState(m)
+	os.Exit(status)
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// transport is the connection to the HID device, as provided by one of
+// the backends.
+type transport interface {
+	SendFeatureReport(data []byte) (int, error)
+	// GetFeatureReport returns the report including its ID as first
+	// byte. size does not include the report ID.
+	GetFeatureReport(reportId byte, size int) ([]byte, error)
+	Close()
+}
+
+// inputReader reads the input reports of one interface of the mouse.
+type inputReader interface {
+	// ReadInput waits up to timeout for an input report, and returns
+	// 0 if none arrived. The report starts with its ID, if the
+	// interface uses report IDs.
+	ReadInput(buf []byte, timeout time.Duration) (int, error)
+	Close()
+}
+
+type backend struct {
+	// open opens the given USB interface of the mouse, or lets the
+	// backend choose if intf is -1.
+	open      func(intf int) (transport, error)
+	openInput func(intf int) (inputReader, error)
+	present   func() (bool, error)
+}
+
+// backends available in this build, registered by the init function of
+// each backend's source file. The hidapi backend can be left out of the
+// build with the nohidapi build tag, which removes the dependency on
+// cgo.
+var backends = make(map[string]*backend)
+
+// BackendEnv is the environment variable that selects the backend to
+// use at runtime.
+const BackendEnv = "ANKER_MOUSE_BACKEND"
+
+// Backends returns the names of the backends available in this build.
+func Backends() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// DefaultBackend returns the name of the backend used by Open: the one
+// selected in the environment, if any, or hidapi when available.
+func DefaultBackend() string {
+	if name := os.Getenv(BackendEnv); name != "" {
+		return name
+	}
+
+	if _, ok := backends["hidapi"]; ok {
+		return "hidapi"
+	}
+
+	if names := Backends(); len(names) > 0 {
+		return names[0]
+	}
+
+	return ""
+}
+
+func getBackend(name string) (*backend, error) {
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("Backend %q is not available (available: %v)", name, Backends())
+	}
+
+	return b, nil
+}
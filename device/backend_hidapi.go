@@ -0,0 +1,99 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !nohidapi
+
+package device
+
+import (
+	"fmt"
+	"github.com/GeertJohan/go.hid"
+	"time"
+)
+
+func init() {
+	backends["hidapi"] = &backend{
+		open:      openHidapi,
+		openInput: openHidapiInput,
+		present:   presentHidapi,
+	}
+}
+
+func openHidapi(intf int) (transport, error) {
+	if intf < 0 {
+		d, err := hid.Open(HoltekVendorId, AnkerMouseDeviceId, "")
+		if err != nil {
+			return nil, err
+		}
+
+		return d, nil
+	}
+
+	return openHidapiInterface(intf)
+}
+
+func openHidapiInterface(intf int) (*hid.Device, error) {
+	devs, err := hid.Enumerate(HoltekVendorId, AnkerMouseDeviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range devs {
+		if info.InterfaceNumber != intf {
+			continue
+		}
+
+		return hid.OpenPath(info.Path)
+	}
+
+	return nil, fmt.Errorf("Interface %v of the device not found by hidapi", intf)
+}
+
+type hidapiInput struct {
+	d *hid.Device
+}
+
+func openHidapiInput(intf int) (inputReader, error) {
+	d, err := openHidapiInterface(intf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hidapiInput{d: d}, nil
+}
+
+func (self *hidapiInput) ReadInput(buf []byte, timeout time.Duration) (int, error) {
+	return self.d.ReadTimeout(buf, int(timeout/time.Millisecond))
+}
+
+func (self *hidapiInput) Close() {
+	self.d.Close()
+}
+
+func presentHidapi() (bool, error) {
+	devs, err := hid.Enumerate(HoltekVendorId, AnkerMouseDeviceId)
+	if err != nil {
+		return false, err
+	}
+
+	return len(devs) > 0, nil
+}
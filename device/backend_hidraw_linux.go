@@ -0,0 +1,153 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package device
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/internal/ioctl"
+	"os"
+	"time"
+)
+
+// The hidraw backend talks directly to the kernel hidraw driver, without
+// hidapi or cgo.
+
+func init() {
+	backends["hidraw"] = &backend{
+		open:      openHidraw,
+		openInput: openHidrawInput,
+		present:   presentHidraw,
+	}
+}
+
+func hidiocsfeature(size int) uintptr {
+	return ioctl.IOC(ioctl.Write|ioctl.Read, 'H', 0x06, uintptr(size))
+}
+
+func hidiocgfeature(size int) uintptr {
+	return ioctl.IOC(ioctl.Write|ioctl.Read, 'H', 0x07, uintptr(size))
+}
+
+type hidrawTransport struct {
+	f *os.File
+}
+
+func openHidraw(intf int) (transport, error) {
+	nodes, err := findHidrawNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("No hidraw node found for %04x:%04x", HoltekVendorId, AnkerMouseDeviceId)
+	}
+
+	// Without the descriptors, the backend chooses.
+	node := nodes[0]
+	if intf >= 0 {
+		node = nil
+		for _, n := range nodes {
+			if n.intf == intf {
+				node = n
+			}
+		}
+		if node == nil {
+			return nil, fmt.Errorf("No hidraw node found for interface %v", intf)
+		}
+	}
+
+	f, err := os.OpenFile(node.path(), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hidrawTransport{f: f}, nil
+}
+
+func openHidrawInput(intf int) (inputReader, error) {
+	nodes, err := findHidrawNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		if n.intf != intf {
+			continue
+		}
+
+		f, err := os.OpenFile(n.path(), os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return &hidrawTransport{f: f}, nil
+	}
+
+	return nil, fmt.Errorf("No hidraw node found for interface %v", intf)
+}
+
+func presentHidraw() (bool, error) {
+	return len(mouseHidrawNodes()) > 0, nil
+}
+
+func (self *hidrawTransport) SendFeatureReport(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("Empty feature report")
+	}
+
+	return ioctl.Buffer(self.f, hidiocsfeature(len(data)), data)
+}
+
+func (self *hidrawTransport) GetFeatureReport(reportId byte, size int) ([]byte, error) {
+	buf := make([]byte, size+1)
+	buf[0] = reportId
+
+	n, err := ioctl.Buffer(self.f, hidiocgfeature(len(buf)), buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// ReadInput relies on the hidraw nodes being pollable, so that reads
+// can time out.
+func (self *hidrawTransport) ReadInput(buf []byte, timeout time.Duration) (int, error) {
+	if err := self.f.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	n, err := self.f.Read(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+func (self *hidrawTransport) Close() {
+	self.f.Close()
+}
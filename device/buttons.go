@@ -0,0 +1,75 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// hidButtons are the buttons, in the mouse input reports, sent by the
+// button events. Back is only guessed to be button 4, as it is on other
+// five-button mice.
+var hidButtons = map[byte]int{
+	EventLeftClick:   1,
+	EventRightClick:  2,
+	EventMiddleCLick: 3,
+	EventBack:        4,
+	EventForward:     5,
+}
+
+func (self ButtonEntry) String() string {
+	switch self.EventId {
+	case EventDisabled:
+		return "disabled"
+	case EventLeftClick:
+		return "left click"
+	case EventRightClick:
+		return "right click"
+	case EventMiddleCLick:
+		return "middle click"
+	case EventBack:
+		return "back"
+	case EventForward:
+		return "forward"
+	case EventSingleKey:
+		return fmt.Sprintf("key %v", KeyName(self.KeyId))
+	case EventDPISwitch:
+		return "DPI switch"
+	}
+
+	return fmt.Sprintf("event %#02x (%#02x, %#04x)", self.EventId, self.ExtendedInfo, self.KeyId)
+}
+
+// Produces reports whether the event is what the button is expected to
+// send when pressed or released.
+func (self ButtonEntry) Produces(ev Event) bool {
+	switch ev.Kind {
+	case ButtonEvent:
+		b, ok := hidButtons[self.EventId]
+		return ok && b == ev.Button
+	case KeyEvent:
+		return self.EventId == EventSingleKey && ev.Usage == usagePageKeyboard<<16|uint32(self.KeyId)
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device_test
+
+import (
+	"testing"
+
+	"github.com/flameeyes/anker-mouse-tool/device"
+)
+
+func TestButtonEntryProduces(t *testing.T) {
+	button := func(n int) device.Event {
+		return device.Event{Kind: device.ButtonEvent, Button: n, Usage: 0x090000 | uint32(n), Pressed: true}
+	}
+	key := func(usage uint32) device.Event {
+		return device.Event{Kind: device.KeyEvent, Usage: 0x070000 | usage, Pressed: true}
+	}
+
+	tests := []struct {
+		entry device.ButtonEntry
+		ev    device.Event
+		want  bool
+	}{
+		{device.ButtonEntry{EventId: device.EventLeftClick}, button(1), true},
+		{device.ButtonEntry{EventId: device.EventRightClick}, button(2), true},
+		{device.ButtonEntry{EventId: device.EventMiddleCLick}, button(3), true},
+		{device.ButtonEntry{EventId: device.EventBack}, button(4), true},
+		{device.ButtonEntry{EventId: device.EventForward}, button(5), true},
+		{device.ButtonEntry{EventId: device.EventBack}, button(5), false},
+		{device.ButtonEntry{EventId: device.EventLeftClick}, key(0x04), false},
+		{device.ButtonEntry{EventId: device.EventSingleKey, KeyId: 0x04}, key(0x04), true},
+		{device.ButtonEntry{EventId: device.EventSingleKey, KeyId: 0x04}, key(0x05), false},
+		{device.ButtonEntry{EventId: device.EventSingleKey, KeyId: 0x04}, button(4), false},
+		{device.ButtonEntry{EventId: device.EventDPISwitch}, button(4), false},
+		{device.ButtonEntry{EventId: device.EventDisabled}, button(1), false},
+		{device.ButtonEntry{EventId: device.EventLeftClick}, device.Event{Kind: device.MotionEvent, X: 1}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.entry.Produces(tt.ev); got != tt.want {
+			t.Errorf("%v producing %v: got %v, want %v", tt.entry, tt.ev, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Cassettes are sessions (see ReadSession) used as test fixtures: they
+// are recorded once on a real mouse with RecordCassette, then played
+// back by OpenCassette in place of the mouse, so that the reports sent
+// by SetLight, SetProfile or Config.Write can be checked anywhere.
+
+// ErrCassetteMismatch is wrapped by the errors for reports that differ
+// from the ones in the cassette.
+var ErrCassetteMismatch = errors.New("Report does not match the cassette")
+
+// Cassette is a transport playing back a recorded session: each report
+// sent has to be the next one sent in the session, and each report read
+// returns the next one received in the session. Once a report differs,
+// every following one fails.
+type Cassette struct {
+	mu      sync.Mutex
+	reports []*ReportInfo
+	pos     int
+	err     error
+}
+
+func NewCassette(reports []*ReportInfo) *Cassette {
+	return &Cassette{reports: reports}
+}
+
+// LoadCassette reads a cassette from a session file.
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reports, err := ReadSession(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCassette(reports), nil
+}
+
+// RecordCassette opens the mouse, recording to path every report
+// exchanged with it until the device is closed.
+func RecordCassette(path string) (*Device, error) {
+	dev, err := Open()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+
+	dev.files = append(dev.files, f)
+	dev.RecordSession(f)
+
+	return dev, nil
+}
+
+// OpenCassette returns a device playing back the cassette instead of
+// using the mouse. The reports are not checked against any report
+// descriptor, and there are no input events.
+func OpenCassette(c *Cassette) (*Device, error) {
+	dev := &Device{t: c}
+
+	if err := dev.traceFromEnv(); err != nil {
+		return nil, err
+	}
+
+	return dev, nil
+}
+
+// mismatch records the first divergence from the cassette. It has to
+// be called with the lock held.
+func (self *Cassette) mismatch(format string, args ...interface{}) error {
+	if self.err == nil {
+		self.err = fmt.Errorf("%w: %v", ErrCassetteMismatch, fmt.Sprintf(format, args...))
+	}
+
+	return self.err
+}
+
+// next returns the next report of the cassette, checking that it goes
+// in the given direction.
+func (self *Cassette) next(direction ReportDirection, what string) (*ReportInfo, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	if self.pos >= len(self.reports) {
+		return nil, self.mismatch("%v after the end of the cassette", what)
+	}
+
+	info := self.reports[self.pos]
+	if info.Direction != direction {
+		return nil, self.mismatch("%v at report %v, expected a %v %v report", what, self.pos+1, info.Direction, info.Type)
+	}
+
+	self.pos++
+	return info, nil
+}
+
+func (self *Cassette) SendFeatureReport(data []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	what := fmt.Sprintf("sent %v report [% x]", ReportType(data), data)
+	info, err := self.next(ReportSent, what)
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.Equal(info.Data, data) {
+		return 0, self.mismatch("%v at report %v, expected %v report [% x]", what, self.pos, info.Type, info.Data)
+	}
+
+	return len(data), info.Err
+}
+
+func (self *Cassette) GetFeatureReport(reportId byte, size int) ([]byte, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	info, err := self.next(ReportReceived, fmt.Sprintf("read of report %v", reportId))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.Data) > 0 && info.Data[0] != reportId {
+		return nil, self.mismatch("read of report %v at report %v, expected a read of report %v", reportId, self.pos, info.Data[0])
+	}
+
+	return info.Data, info.Err
+}
+
+func (self *Cassette) Close() {
+}
+
+// Done returns the first divergence from the cassette, if any, or an
+// error if some of its reports were never sent or read.
+func (self *Cassette) Done() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.err != nil {
+		return self.err
+	}
+
+	if left := len(self.reports) - self.pos; left > 0 {
+		return self.mismatch("%v reports of the cassette were not used, starting with a %v %v report", left, self.reports[self.pos].Direction, self.reports[self.pos].Type)
+	}
+
+	return nil
+}
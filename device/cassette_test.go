@@ -0,0 +1,98 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device_test
+
+import (
+	"errors"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/device/devicetest"
+	"testing"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+)
+
+func TestCassettes(t *testing.T) {
+	tests := []struct {
+		cassette string
+		run      func(dev *device.Device) error
+	}{
+		{"testdata/set-light.jsonl", func(dev *device.Device) error {
+			return dev.SetLight(colorful.Color{R: 1}, 2, 0)
+		}},
+		{"testdata/set-profile.jsonl", func(dev *device.Device) error {
+			return dev.SetProfile(device.Profile2)
+		}},
+		{"testdata/config-write.jsonl", func(dev *device.Device) error {
+			cfg := device.NewConfig()
+			cfg.Profiles[1].LightProfile.SetColor(colorful.Color{G: 1})
+			return cfg.Write(dev)
+		}},
+		{"testdata/reset-profile-2.jsonl", func(dev *device.Device) error {
+			return dev.Reset(device.Profile2)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cassette, func(t *testing.T) {
+			dev := devicetest.OpenCassette(t, tt.cassette)
+			if err := tt.run(dev); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCassetteMismatch(t *testing.T) {
+	c, err := device.LoadCassette("testdata/set-light.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := device.OpenCassette(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+
+	if err := dev.SetLight(colorful.Color{B: 1}, 2, 0); !errors.Is(err, device.ErrCassetteMismatch) {
+		t.Errorf("SetLight with another color returned %v, want ErrCassetteMismatch", err)
+	}
+	if err := c.Done(); !errors.Is(err, device.ErrCassetteMismatch) {
+		t.Errorf("Done returned %v, want ErrCassetteMismatch", err)
+	}
+}
+
+func TestCassetteUnused(t *testing.T) {
+	c, err := device.LoadCassette("testdata/set-profile.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := device.OpenCassette(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+
+	if err := c.Done(); err == nil {
+		t.Error("Done succeeded with none of the reports sent")
+	}
+}
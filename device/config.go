@@ -20,13 +20,12 @@
 // CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package main
+package device
 
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"github.com/GeertJohan/go.hid"
 	colorful "github.com/lucasb-eyer/go-colorful"
 )
 
@@ -35,7 +34,7 @@ var (
 		{2, 6, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 		{2, 2, 16, 0, 8, 0, 250, 250, 0, 0, 0, 0, 0, 0, 0, 0},
 		{2, 3, 64, 0, 1, 0, 250, 250, 0, 0, 0, 0, 0, 0, 0, 0},
-		{2, 2, 69, 0, 1, 0, 250, 250, 2 /* hz? */, 0, 0, 0, 0, 0, 0, 0},
+		{0 /* polling rate */},
 		{2, 3, 72, 0, 32, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 		{0 /* buttons profile 1 */},
 		{3, 2, 209, 0, 21, 0, 250, 250, 129, 1, 1, 6, 1, 0, 1, 1, 1, 6, 2, 0, 129, 1, 1, 6, 1, 0, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
@@ -50,6 +49,7 @@ var (
 )
 
 const (
+	PollingRateIdx     = 3
 	ButtonsProfile1Idx = 5
 	LightProfile1Idx   = 7
 	DPIProfile1Idx     = 8
@@ -58,6 +58,13 @@ const (
 	DPIProfile2Idx     = 12
 )
 
+// The first and last report of each profile in the configuration;
+// the reports before are the preamble, and the one after the commit.
+var profileReports = map[ProfileID][2]int{
+	Profile1: {ButtonsProfile1Idx, DPIProfile1Idx},
+	Profile2: {ButtonsProfile2Idx, DPIProfile2Idx},
+}
+
 const (
 	EventDisabled    = 0x0e
 	EventLeftClick   = 0x01
@@ -65,13 +72,19 @@ const (
 	EventMiddleCLick = 0x03
 	EventForward     = 0x05
 	EventSingleKey   = 0x10
+	EventDPISwitch   = 0x13 // Cycles through DPI levels (and profiles?), with ExtendedInfo 0x80
+
+	// Only known from the default mapping of the vendor tool; what
+	// they do is a guess.
+	EventBack        = 0x04
+	EventMacroRecord = 0x08
+	EventMacroPlay   = 0x11
 )
 
 type ButtonEntry struct {
-	EventId      byte   // Event* constants above
-	ExtendedInfo byte   // Still-unclear
-	KeyId        uint16 // USB Scancodes if EventSingleKey
-
+	EventId      byte   `json:"event"`         // Event* constants above
+	ExtendedInfo byte   `json:"extended_info"` // Still-unclear
+	KeyId        uint16 `json:"key"`           // USB Scancodes if EventSingleKey
 }
 
 type ButtonsProfile struct {
@@ -85,13 +98,13 @@ type ButtonsProfile struct {
 	Unknown    [978]byte // All zeroes
 }
 
-func NewButtonsProfile(profile int) *ButtonsProfile {
+func NewButtonsProfile(profile ProfileID) *ButtonsProfile {
 	var profileId byte
 
 	switch profile {
-	case 1:
+	case Profile1:
 		profileId = 0x00
-	case 2:
+	case Profile2:
 		profileId = 0x09
 	}
 
@@ -138,18 +151,18 @@ type LightProfile struct {
 	InverseRed   byte
 	InverseGreen byte
 	InverseBlue  byte
-	Brightness   byte
-	BreathSpeed  byte
+	Brightness   Brightness
+	BreathSpeed  BreathSpeed
 	Constant6    [3]byte // 0x00 0x00 0x00
 }
 
-func NewLightProfile(profile int) *LightProfile {
+func NewLightProfile(profile ProfileID) *LightProfile {
 	var profileId byte
 
 	switch profile {
-	case 1:
+	case Profile1:
 		profileId = 0x08
-	case 2:
+	case Profile2:
 		profileId = 0x11
 	}
 
@@ -170,6 +183,14 @@ func (self *LightProfile) SetColor(c colorful.Color) {
 	self.InverseBlue = ^b
 }
 
+func (self *LightProfile) Color() colorful.Color {
+	return colorful.Color{
+		R: float64(^self.InverseRed) / 255.0,
+		G: float64(^self.InverseGreen) / 255.0,
+		B: float64(^self.InverseBlue) / 255.0,
+	}
+}
+
 func (self *LightProfile) ToBytes() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	err := binary.Write(buf, binary.LittleEndian, self)
@@ -192,18 +213,18 @@ type DPIProfile struct {
 	ProfileId  byte    // Profile1=0x00 Profile2=0x09
 	Constant1  [6]byte // 0x20 0x00 0xFA 0xFA 0x04 0x01 (last one not sure is a constant)
 	DPI        [4]dpiEntry
-	Unknown    [42]byte // All Zeroes
+	Unknown    [43]byte // All Zeroes
 }
 
 var DPIProfileConstant1 = [6]byte{0x20, 0x00, 0xFA, 0xFA, 0x04, 0x01}
 
-func NewDPIProfile(profile int) *DPIProfile {
+func NewDPIProfile(profile ProfileID) *DPIProfile {
 	var profileId byte
 
 	switch profile {
-	case 1:
+	case Profile1:
 		profileId = 0x00
-	case 2:
+	case Profile2:
 		profileId = 0x09
 	}
 
@@ -221,19 +242,48 @@ func NewDPIProfile(profile int) *DPIProfile {
 	}
 }
 
-func (self *DPIProfile) SetDPI(dpi [4][2]int) {
+// SetDPI sets the X and Y DPI for each level; a level with a zero X
+// value is disabled. Nothing is changed if any of the values is
+// invalid.
+func (self *DPIProfile) SetDPI(dpi [4][2]DPI) error {
+	for i := range dpi {
+		if dpi[i][0] == 0 {
+			continue
+		}
+
+		for _, d := range dpi[i] {
+			if !d.Valid() {
+				return fmt.Errorf("Invalid DPI for level %v: %v", i+1, d)
+			}
+		}
+	}
+
 	for i := range self.DPI {
-		// If the DPI value is 0, we consider it disabled.
 		if dpi[i][0] == 0 {
 			self.DPI[i].Enabled = 0
 		} else {
 			self.DPI[i] = dpiEntry{
 				Enabled: 1,
-				X:       byte(dpi[i][0] / 50),
-				Y:       byte(dpi[i][1] / 50),
+				X:       dpi[i][0].units(),
+				Y:       dpi[i][1].units(),
 			}
 		}
 	}
+
+	return nil
+}
+
+// DPIValues returns the X and Y DPI for each level, in the same format
+// accepted by SetDPI.
+func (self *DPIProfile) DPIValues() [4][2]DPI {
+	var dpi [4][2]DPI
+	for i, e := range self.DPI {
+		if e.Enabled != 0 {
+			dpi[i] = [2]DPI{DPI(e.X) * DPIStep, DPI(e.Y) * DPIStep}
+		}
+	}
+
+	return dpi
 }
 
 func (self *DPIProfile) ToBytes() ([]byte, error) {
@@ -252,7 +302,7 @@ type ConfigProfile struct {
 	*DPIProfile
 }
 
-func NewConfigProfile(profile int) *ConfigProfile {
+func NewConfigProfile(profile ProfileID) *ConfigProfile {
 	return &ConfigProfile{
 		ButtonsProfile: NewButtonsProfile(profile),
 		LightProfile:   NewLightProfile(profile),
@@ -260,25 +310,85 @@ func NewConfigProfile(profile int) *ConfigProfile {
 	}
 }
 
+// Config is the full configuration of the mouse. The polling rate is
+// only written when set, leaving the one in the mouse alone otherwise.
 type Config struct {
-	Profiles [2]*ConfigProfile
+	Profiles    [2]*ConfigProfile
+	PollingRate PollingRate
 }
 
 func NewConfig() *Config {
 	return &Config{
 		Profiles: [2]*ConfigProfile{
-			NewConfigProfile(1),
-			NewConfigProfile(2),
+			NewConfigProfile(Profile1),
+			NewConfigProfile(Profile2),
 		},
 	}
 }
 
-func (self *Config) Write(device *hid.Device) error {
-	reports := configuration
+// Write validates the configuration, checks it with Lint, and writes
+// it to the device. Configurations with lint issues are refused with a
+// *LintError; use WriteForce to write them anyway.
+func (self *Config) Write(dev *Device) error {
+	if issues := self.Lint(); len(issues) > 0 {
+		return &LintError{Issues: issues}
+	}
+
+	return self.WriteForce(dev)
+}
+
+// WriteForce validates the configuration and writes it to the device,
+// without checking it with Lint.
+func (self *Config) WriteForce(dev *Device) error {
+	return self.writeProfiles(dev, Profile1, Profile2)
+}
+
+// writeProfiles writes the preamble, the reports of the given profiles
+// and the commit report.
+func (self *Config) writeProfiles(dev *Device, profiles ...ProfileID) error {
+	if err := self.Validate(); err != nil {
+		return err
+	}
+
+	skip := make(map[int]bool)
+	for _, rows := range profileReports {
+		for i := rows[0]; i <= rows[1]; i++ {
+			skip[i] = true
+		}
+	}
+	for _, p := range profiles {
+		if !p.Valid() {
+			return fmt.Errorf("Invalid profile: %v", p)
+		}
+
+		rows := profileReports[p]
+		for i := rows[0]; i <= rows[1]; i++ {
+			skip[i] = false
+		}
+	}
+
+	if self.PollingRate != 0 && !self.PollingRate.captured() {
+		if err := checkExperimental(fmt.Sprintf("Polling rate %v", self.PollingRate)); err != nil {
+			return err
+		}
+	}
+
+	reports := make([][]byte, len(configuration))
+	copy(reports, configuration)
 
 	var r []byte
 	var err error
 
+	if self.PollingRate != 0 {
+		r, err = reportBytes(newMemoryWriteReport(pollingRateAddress, self.PollingRate.interval()))
+		if err != nil {
+			return err
+		}
+		reports[PollingRateIdx] = r
+	} else {
+		skip[PollingRateIdx] = true
+	}
+
 	r, err = self.Profiles[0].ButtonsProfile.ToBytes()
 	if err != nil {
 		return err
@@ -297,7 +407,7 @@ func (self *Config) Write(device *hid.Device) error {
 	}
 	reports[DPIProfile1Idx] = r
 
-	r, err = self.Profiles[0].ButtonsProfile.ToBytes()
+	r, err = self.Profiles[1].ButtonsProfile.ToBytes()
 	if err != nil {
 		return err
 	}
@@ -316,9 +426,13 @@ func (self *Config) Write(device *hid.Device) error {
 	reports[DPIProfile2Idx] = r
 
 	for i, r := range reports {
-		_, err := device.SendFeatureReport(r)
+		if skip[i] {
+			continue
+		}
+
+		err := dev.WriteFeatureReport(r)
 		if err != nil {
-			return fmt.Errorf("Error writing report %v: %v", i, err)
+			return fmt.Errorf("Error writing report %v: %w", i, err)
 		}
 	}
 
@@ -0,0 +1,149 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	colorful "github.com/lucasb-eyer/go-colorful"
+)
+
+// The JSON representation of a Config only carries the configurable
+// values, leaving out the report framing, e.g.:
+//
+//	{
+//	  "profiles": [
+//	    {
+//	      "light": {"color": "#0000ff", "brightness": 2, "breath_speed": 0},
+//	      "dpi": [{"x": 1000, "y": 1000}, {"x": 2000, "y": 2000}, null, null],
+//	      "buttons": [{"event": 1, "extended_info": 0, "key": 0}, ...]
+//	    },
+//	    ...
+//	  ],
+//	  "polling_rate": 500
+//	}
+//
+// A null DPI level is disabled. Without a polling_rate, the one set in
+// the mouse is left alone.
+
+type lightJSON struct {
+	Color       colorful.HexColor `json:"color"`
+	Brightness  Brightness        `json:"brightness"`
+	BreathSpeed BreathSpeed       `json:"breath_speed"`
+}
+
+type dpiJSON struct {
+	X DPI `json:"x"`
+	Y DPI `json:"y"`
+}
+
+type profileJSON struct {
+	Light   lightJSON     `json:"light"`
+	DPI     [4]*dpiJSON   `json:"dpi"`
+	Buttons []ButtonEntry `json:"buttons"`
+}
+
+type configJSON struct {
+	Profiles    [2]profileJSON `json:"profiles"`
+	PollingRate PollingRate    `json:"polling_rate,omitempty"`
+}
+
+func newConfigJSON(cfg *Config) *configJSON {
+	cj := new(configJSON)
+
+	for i, p := range cfg.Profiles {
+		pj := &cj.Profiles[i]
+
+		pj.Light = lightJSON{
+			Color:       colorful.HexColor(p.LightProfile.Color()),
+			Brightness:  p.LightProfile.Brightness,
+			BreathSpeed: p.LightProfile.BreathSpeed,
+		}
+
+		for j, dpi := range p.DPIProfile.DPIValues() {
+			if dpi[0] != 0 {
+				pj.DPI[j] = &dpiJSON{X: dpi[0], Y: dpi[1]}
+			}
+		}
+
+		pj.Buttons = p.ButtonsProfile.Buttons[:]
+	}
+
+	cj.PollingRate = cfg.PollingRate
+
+	return cj
+}
+
+func (self *Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newConfigJSON(self))
+}
+
+// UnmarshalJSON starts from the default configuration, so that values
+// missing from the JSON representation keep their defaults. The result
+// is validated, and all problems are reported at once.
+func (self *Config) UnmarshalJSON(data []byte) error {
+	def := NewConfig()
+	cj := newConfigJSON(def)
+
+	if err := json.Unmarshal(data, cj); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, p := range def.Profiles {
+		pj := &cj.Profiles[i]
+
+		p.LightProfile.SetColor(colorful.Color(pj.Light.Color))
+		p.LightProfile.Brightness = pj.Light.Brightness
+		p.LightProfile.BreathSpeed = pj.Light.BreathSpeed
+
+		var dpi [4][2]DPI
+		for j, d := range pj.DPI {
+			if d != nil {
+				dpi[j] = [2]DPI{d.X, d.Y}
+			}
+		}
+		if err := p.DPIProfile.SetDPI(dpi); err != nil {
+			errs = append(errs, fmt.Errorf("Profile %v: %v", i+1, err))
+		}
+
+		// All the buttons have to be listed, or none to keep the
+		// default mapping.
+		if len(pj.Buttons) != len(p.ButtonsProfile.Buttons) {
+			errs = append(errs, fmt.Errorf("Profile %v: %v buttons listed, rather than %v", i+1, len(pj.Buttons), len(p.ButtonsProfile.Buttons)))
+			continue
+		}
+		copy(p.ButtonsProfile.Buttons[:], pj.Buttons)
+	}
+
+	def.PollingRate = cj.PollingRate
+
+	errs = append(errs, def.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	*self = *def
+	return nil
+}
@@ -0,0 +1,375 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReportDescriptor summarises a HID report descriptor as the size, in
+// bytes and excluding the report ID, of each report it declares, and
+// the layout of the input reports.
+type ReportDescriptor struct {
+	Input   map[byte]int
+	Output  map[byte]int
+	Feature map[byte]int
+
+	// Whether the reports are prefixed by their ID.
+	hasReportIds bool
+	inputFields  map[byte][]*reportField
+}
+
+// reportField is one of the values in an input report. Usages include
+// their usage page in the high 16 bits.
+type reportField struct {
+	offset uint32 // in bits, excluding the report ID
+	size   uint32 // in bits
+	signed bool
+
+	// For variable fields, the usage the value refers to. For array
+	// fields, the value is an index in the usages starting at usage.
+	usage    uint32
+	array    bool
+	arrayMin int32
+}
+
+func (self *reportField) usagePage() uint16 {
+	return uint16(self.usage >> 16)
+}
+
+// Item tags, from the HID 1.11 specification, section 6.2.2.
+const (
+	itemTypeMain   = 0
+	itemTypeGlobal = 1
+	itemTypeLocal  = 2
+
+	mainInput   = 0x8
+	mainOutput  = 0x9
+	mainFeature = 0xb
+
+	mainFlagConstant = 0x1
+	mainFlagVariable = 0x2
+
+	globalUsagePage   = 0x0
+	globalLogicalMin  = 0x1
+	globalReportSize  = 0x7
+	globalReportId    = 0x8
+	globalReportCount = 0x9
+	globalPush        = 0xa
+	globalPop         = 0xb
+
+	localUsage    = 0x0
+	localUsageMin = 0x1
+	localUsageMax = 0x2
+
+	longItemPrefix = 0xfe
+)
+
+type descriptorGlobals struct {
+	usagePage   uint16
+	logicalMin  int32
+	reportSize  uint32
+	reportId    byte
+	reportCount uint32
+}
+
+type descriptorLocals struct {
+	usages   []uint32
+	usageMin uint32
+	usageMax uint32
+
+	// Whether each of usages, usageMin and usageMax was given in one
+	// or two bytes, and so is in the usage page current at the main
+	// item rather than at the local item.
+	pageless    []bool
+	minPageless bool
+	maxPageless bool
+}
+
+// setUsagePage adds the usage page to the usages given without one.
+func (self *descriptorLocals) setUsagePage(page uint16) {
+	for i, pageless := range self.pageless {
+		if pageless {
+			self.usages[i] |= uint32(page) << 16
+		}
+	}
+	if self.minPageless {
+		self.usageMin |= uint32(page) << 16
+	}
+	if self.maxPageless {
+		self.usageMax |= uint32(page) << 16
+	}
+}
+
+// usage returns the usage of the i-th value of a variable main item.
+func (self *descriptorLocals) usage(i int) uint32 {
+	if len(self.usages) > 0 {
+		if i >= len(self.usages) {
+			i = len(self.usages) - 1
+		}
+		return self.usages[i]
+	}
+
+	if u := self.usageMin + uint32(i); u <= self.usageMax {
+		return u
+	}
+
+	return self.usageMax
+}
+
+// ParseReportDescriptor parses a raw HID report descriptor, only
+// keeping track of what is needed to size the reports and to decode
+// the input reports.
+func ParseReportDescriptor(data []byte) (*ReportDescriptor, error) {
+	bits := map[int]map[byte]uint32{
+		mainInput:   make(map[byte]uint32),
+		mainOutput:  make(map[byte]uint32),
+		mainFeature: make(map[byte]uint32),
+	}
+
+	desc := &ReportDescriptor{
+		inputFields: make(map[byte][]*reportField),
+	}
+
+	var globals descriptorGlobals
+	var locals descriptorLocals
+	var stack []descriptorGlobals
+
+	for i := 0; i < len(data); {
+		prefix := data[i]
+
+		if prefix == longItemPrefix {
+			if i+1 >= len(data) {
+				return nil, fmt.Errorf("Truncated long item at offset %v", i)
+			}
+			i += 3 + int(data[i+1])
+			continue
+		}
+
+		size := int(prefix & 0x3)
+		if size == 3 {
+			size = 4
+		}
+		itemType := (prefix >> 2) & 0x3
+		tag := prefix >> 4
+
+		if i+1+size > len(data) {
+			return nil, fmt.Errorf("Truncated item at offset %v", i)
+		}
+
+		var value uint32
+		for j := 0; j < size; j++ {
+			value |= uint32(data[i+1+j]) << (8 * uint(j))
+		}
+		i += 1 + size
+
+		switch itemType {
+		case itemTypeMain:
+			// Usages of one or two bytes are in the usage page
+			// current here, which might have been changed after
+			// them.
+			locals.setUsagePage(globals.usagePage)
+			if tag == mainInput && value&mainFlagConstant == 0 {
+				desc.addInputFields(globals, &locals, value&mainFlagVariable != 0, bits[mainInput][globals.reportId])
+			}
+			if b, ok := bits[int(tag)]; ok {
+				b[globals.reportId] += globals.reportSize * globals.reportCount
+			}
+			locals = descriptorLocals{}
+
+		case itemTypeLocal:
+			switch tag {
+			case localUsage:
+				locals.usages = append(locals.usages, value)
+				locals.pageless = append(locals.pageless, size < 4)
+			case localUsageMin:
+				locals.usageMin, locals.minPageless = value, size < 4
+			case localUsageMax:
+				locals.usageMax, locals.maxPageless = value, size < 4
+			}
+
+		case itemTypeGlobal:
+			switch tag {
+			case globalUsagePage:
+				globals.usagePage = uint16(value)
+			case globalLogicalMin:
+				// Logical Minimum is signed, in as many bytes as
+				// the item holds.
+				if size > 0 && size < 4 && value&(1<<(8*uint(size)-1)) != 0 {
+					value |= ^uint32(0) << (8 * uint(size))
+				}
+				globals.logicalMin = int32(value)
+			case globalReportSize:
+				globals.reportSize = value
+			case globalReportId:
+				globals.reportId = byte(value)
+				desc.hasReportIds = true
+			case globalReportCount:
+				globals.reportCount = value
+			case globalPush:
+				stack = append(stack, globals)
+			case globalPop:
+				if len(stack) == 0 {
+					return nil, fmt.Errorf("Pop without Push at offset %v", i)
+				}
+				globals = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	toBytes := func(b map[byte]uint32) map[byte]int {
+		m := make(map[byte]int)
+		for id, n := range b {
+			m[id] = int((n + 7) / 8)
+		}
+		return m
+	}
+
+	desc.Input = toBytes(bits[mainInput])
+	desc.Output = toBytes(bits[mainOutput])
+	desc.Feature = toBytes(bits[mainFeature])
+
+	return desc, nil
+}
+
+func (self *ReportDescriptor) addInputFields(globals descriptorGlobals, locals *descriptorLocals, variable bool, offset uint32) {
+	id := globals.reportId
+
+	// Without usages, the values at least belong to the usage page.
+	if len(locals.usages) == 0 && locals.usageMin == 0 && locals.usageMax == 0 {
+		locals.usageMin = uint32(globals.usagePage) << 16
+		locals.usageMax = locals.usageMin
+	}
+
+	if !variable {
+		// An array of indexes into the usages, e.g. the keys pressed
+		// on a keyboard.
+		min := locals.usageMin
+		if len(locals.usages) > 0 {
+			min = locals.usages[0]
+		}
+		for i := uint32(0); i < globals.reportCount; i++ {
+			self.inputFields[id] = append(self.inputFields[id], &reportField{
+				offset:   offset + i*globals.reportSize,
+				size:     globals.reportSize,
+				signed:   globals.logicalMin < 0,
+				usage:    min,
+				array:    true,
+				arrayMin: globals.logicalMin,
+			})
+		}
+		return
+	}
+
+	for i := uint32(0); i < globals.reportCount; i++ {
+		self.inputFields[id] = append(self.inputFields[id], &reportField{
+			offset: offset + i*globals.reportSize,
+			size:   globals.reportSize,
+			signed: globals.logicalMin < 0,
+			usage:  locals.usage(int(i)),
+		})
+	}
+}
+
+// vendorFeatureReports are the feature report IDs used to configure the
+// mouse.
+var vendorFeatureReports = []byte{2, 3, 4}
+
+func (self *ReportDescriptor) vendorReports() int {
+	n := 0
+	for _, id := range vendorFeatureReports {
+		if _, ok := self.Feature[id]; ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// featureInterface returns the interface declaring a feature report,
+// or -1 if none does.
+func featureInterface(descs map[int]*ReportDescriptor, id byte) int {
+	var intfs []int
+	for intf := range descs {
+		intfs = append(intfs, intf)
+	}
+	sort.Ints(intfs)
+
+	for _, intf := range intfs {
+		if _, ok := descs[intf].Feature[id]; ok {
+			return intf
+		}
+	}
+
+	return -1
+}
+
+// selectInterface returns the interface that declares the most of the
+// vendor feature reports, to open first, or -1 if the descriptors are
+// not known.
+func selectInterface(descs map[int]*ReportDescriptor) (int, *ReportDescriptor, error) {
+	if len(descs) == 0 {
+		return -1, nil, nil
+	}
+
+	var intfs []int
+	for intf := range descs {
+		intfs = append(intfs, intf)
+	}
+	sort.Ints(intfs)
+
+	best, bestCount := -1, 0
+	for _, intf := range intfs {
+		if n := descs[intf].vendorReports(); n > bestCount {
+			best, bestCount = intf, n
+		}
+	}
+
+	if best < 0 {
+		return -1, nil, fmt.Errorf("None of the device interfaces declares feature reports %v", vendorFeatureReports)
+	}
+
+	return best, descs[best], nil
+}
+
+// validateFeatureReport checks that the report, starting with its ID,
+// is declared by the descriptor with the same size.
+func (self *ReportDescriptor) validateFeatureReport(report []byte) error {
+	if len(report) == 0 {
+		return fmt.Errorf("Empty feature report")
+	}
+
+	id := report[0]
+	size, ok := self.Feature[id]
+	if !ok {
+		return fmt.Errorf("Feature report %v is not declared by the device interface", id)
+	}
+
+	if len(report)-1 != size {
+		return fmt.Errorf("Feature report %v is %v bytes long, but the device expects %v", id, len(report)-1, size)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/virtualmouse"
+)
+
+func TestParseReportDescriptor(t *testing.T) {
+	tests := []struct {
+		name                   string
+		data                   []byte
+		input, output, feature map[byte]int
+	}{
+		{
+			name:    "mouse",
+			data:    virtualmouse.MouseDescriptor,
+			input:   map[byte]int{0: 7},
+			output:  map[byte]int{},
+			feature: map[byte]int{},
+		},
+		{
+			name:    "vendor",
+			data:    virtualmouse.VendorDescriptor,
+			input:   map[byte]int{1: 8, 5: 7},
+			output:  map[byte]int{},
+			feature: map[byte]int{2: 15, 3: 63, 4: 1023},
+		},
+		{
+			// From the HID 1.11 specification, appendix B.1.
+			name: "boot keyboard",
+			data: []byte{
+				0x05, 0x01, 0x09, 0x06, 0xa1, 0x01, 0x05, 0x07,
+				0x19, 0xe0, 0x29, 0xe7, 0x15, 0x00, 0x25, 0x01,
+				0x75, 0x01, 0x95, 0x08, 0x81, 0x02, 0x95, 0x01,
+				0x75, 0x08, 0x81, 0x01, 0x95, 0x05, 0x75, 0x01,
+				0x05, 0x08, 0x19, 0x01, 0x29, 0x05, 0x91, 0x02,
+				0x95, 0x01, 0x75, 0x03, 0x91, 0x01, 0x95, 0x06,
+				0x75, 0x08, 0x15, 0x00, 0x25, 0x65, 0x05, 0x07,
+				0x19, 0x00, 0x29, 0x65, 0x81, 0x00, 0xc0,
+			},
+			input:   map[byte]int{0: 8},
+			output:  map[byte]int{0: 1},
+			feature: map[byte]int{},
+		},
+		{
+			name: "push and pop",
+			data: []byte{
+				0x75, 0x08, // Report Size (8)
+				0x95, 0x02, // Report Count (2)
+				0xa4,       // Push
+				0x95, 0x04, // Report Count (4)
+				0xb1, 0x02, // Feature (Data, Variable, Absolute)
+				0xb4,       // Pop
+				0xb1, 0x02, // Feature (Data, Variable, Absolute)
+				0xfe, 0x01, 0x00, 0x00, // Long item, skipped
+			},
+			input:   map[byte]int{},
+			output:  map[byte]int{},
+			feature: map[byte]int{0: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desc, err := device.ParseReportDescriptor(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(desc.Input, tt.input) {
+				t.Errorf("Input reports %v, want %v", desc.Input, tt.input)
+			}
+			if !reflect.DeepEqual(desc.Output, tt.output) {
+				t.Errorf("Output reports %v, want %v", desc.Output, tt.output)
+			}
+			if !reflect.DeepEqual(desc.Feature, tt.feature) {
+				t.Errorf("Feature reports %v, want %v", desc.Feature, tt.feature)
+			}
+		})
+	}
+}
+
+func TestParseReportDescriptorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated item", []byte{0x05, 0x01, 0x06, 0x00}},
+		{"truncated long item", []byte{0xfe}},
+		{"pop without push", []byte{0x75, 0x08, 0xb4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := device.ParseReportDescriptor(tt.data); err == nil {
+				t.Error("ParseReportDescriptor succeeded")
+			}
+		})
+	}
+}
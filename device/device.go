@@ -25,54 +25,228 @@ package device
 import (
 	"bytes"
 	"encoding/binary"
-	"github.com/GeertJohan/go.hid"
+	"errors"
+	"fmt"
 	colorful "github.com/lucasb-eyer/go-colorful"
+	"os"
+	"sync"
+	"time"
 )
 
+// ErrClosed is returned by the reports sent or read once the device
+// is closed.
+var ErrClosed = errors.New("Device is closed")
+
 const (
 	HoltekVendorId     = 0x04d9
 	AnkerMouseDeviceId = 0xfa50
 )
 
 type Device struct {
-	hiddev *hid.Device
+	b *backend
+	t transport
+
+	// Interface of t, and its descriptor, nil if not known.
+	intf       int
+	descriptor *ReportDescriptor
+	// Descriptors of all the interfaces, to read input reports and
+	// to send each feature report to the interface declaring it.
+	descriptors map[int]*ReportDescriptor
+	// Transports of the other interfaces, opened when needed.
+	transports map[int]transport
+
+	// Serialises the use of the transport, so that a memory read is
+	// not interleaved with other reports, and that the transports are
+	// not closed while in use.
+	mu     sync.Mutex
+	closed bool
+
+	observers []func(*ReportInfo)
+	// Files the session is recorded to, closed with the device.
+	files []*os.File
 }
 
+// Open opens the mouse through the default backend.
 func Open() (*Device, error) {
-	d, err := hid.Open(HoltekVendorId, AnkerMouseDeviceId, "")
+	return OpenBackend(DefaultBackend())
+}
+
+// OpenBackend opens the mouse through the named backend, "hidapi" or
+// "hidraw". Where the report descriptors are available, every feature
+// report is sent to the interface declaring it, and checked against its
+// descriptor before being sent.
+func OpenBackend(name string) (*Device, error) {
+	b, err := getBackend(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Device{
-		hiddev: d,
-	}, nil
+	descs, err := InterfaceDescriptors()
+	if err != nil {
+		return nil, err
+	}
+
+	intf, desc, err := selectInterface(descs)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := b.open(intf)
+	if err != nil {
+		return nil, err
+	}
+
+	dev := &Device{
+		b:           b,
+		t:           t,
+		intf:        intf,
+		descriptor:  desc,
+		descriptors: descs,
+		transports:  make(map[int]transport),
+	}
+
+	if err := dev.traceFromEnv(); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	return dev, nil
+}
+
+// Present reports whether a mouse is currently connected, without
+// opening it.
+func Present() (bool, error) {
+	b, err := getBackend(DefaultBackend())
+	if err != nil {
+		return false, err
+	}
+
+	return b.present()
+}
+
+// Close closes the transports, waiting for the reports in progress on
+// other goroutines. The reports sent or read afterwards fail with
+// ErrClosed.
+func (self *Device) Close() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return
+	}
+	self.closed = true
+
+	self.t.Close()
+	for _, t := range self.transports {
+		t.Close()
+	}
+	for _, f := range self.files {
+		f.Close()
+	}
 }
 
 func (self *Device) WriteFeatureReport(report interface{}) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.writeFeatureReport(report)
+}
+
+// transportFor returns the transport of the interface declaring the
+// feature report, and its descriptor. Without descriptors, the reports
+// all go to the interface the backend chose. It has to be called with
+// the lock held, and fails with ErrClosed once the device is closed.
+func (self *Device) transportFor(id byte) (transport, *ReportDescriptor, error) {
+	if self.closed {
+		return nil, nil, ErrClosed
+	}
+	if len(self.descriptors) == 0 {
+		return self.t, self.descriptor, nil
+	}
+
+	intf := featureInterface(self.descriptors, id)
+	switch {
+	case intf < 0:
+		return nil, nil, fmt.Errorf("%w: Feature report %v is not declared by any interface of the device", ErrInvalidReport, id)
+	case intf == self.intf:
+		return self.t, self.descriptor, nil
+	}
+
+	t, ok := self.transports[intf]
+	if !ok {
+		var err error
+		if t, err = self.b.open(intf); err != nil {
+			return nil, nil, err
+		}
+		self.transports[intf] = t
+	}
+
+	return t, self.descriptors[intf], nil
+}
+
+func (self *Device) writeFeatureReport(report interface{}) error {
 	buf := new(bytes.Buffer)
 	err := binary.Write(buf, binary.LittleEndian, report)
 	if err != nil {
 		return err
 	}
 
-	_, err = self.hiddev.SendFeatureReport(buf.Bytes())
+	info := &ReportInfo{
+		Time:      time.Now(),
+		Direction: ReportSent,
+		Type:      ReportType(buf.Bytes()),
+		Data:      buf.Bytes(),
+	}
+	if _, raw := report.([]byte); !raw {
+		info.Report = report
+	}
+
+	if buf.Len() == 0 {
+		info.Err = fmt.Errorf("%w: empty feature report", ErrInvalidReport)
+		self.notify(info)
+		return info.Err
+	}
+
+	t, desc, err := self.transportFor(buf.Bytes()[0])
+	if err == nil && desc != nil {
+		if err = desc.validateFeatureReport(buf.Bytes()); err != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+	}
 	if err != nil {
+		info.Err = err
+		self.notify(info)
 		return err
 	}
 
-	return nil
+	_, err = t.SendFeatureReport(buf.Bytes())
+	info.Duration = time.Since(info.Time)
+	info.Err = err
+	self.notify(info)
+
+	return err
 }
 
-func (self *Device) SetLight(c colorful.Color, brightness, breathspeed byte) error {
+func (self *Device) SetLight(c colorful.Color, brightness Brightness, breathspeed BreathSpeed) error {
+	if !brightness.Valid() {
+		return fmt.Errorf("Invalid brightness: %v", brightness)
+	}
+	if !breathspeed.Valid() {
+		return fmt.Errorf("Invalid breath speed: %v", breathspeed)
+	}
+
 	r, g, b := c.RGB255()
 
 	report := newSetLightReport(r, g, b, brightness, breathspeed)
 	return self.WriteFeatureReport(report)
 }
 
-func (self *Device) SetProfile(profileId byte) rror {
-	r1, r2 := newSetProfileReports(profileId)
+func (self *Device) SetProfile(profile ProfileID) error {
+	if !profile.Valid() {
+		return fmt.Errorf("Invalid profile: %v", profile)
+	}
+
+	r1, r2 := newSetProfileReports(profile.index())
 
 	err := self.WriteFeatureReport(r1)
 	if err != nil {
@@ -81,3 +255,20 @@ func (self *Device) SetProfile(profileId byte) rror {
 
 	return self.WriteFeatureReport(r2)
 }
+
+// ActiveProfile reads which profile is currently active, whether it
+// was selected with SetProfile or with the button on the mouse. Reading
+// the memory of the mouse is experimental (see readMemory).
+func (self *Device) ActiveProfile() (ProfileID, error) {
+	data, err := self.readMemory(activeProfileAddress, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	p := ProfileID(data[0] + 1)
+	if !p.Valid() {
+		return 0, fmt.Errorf("Unknown active profile %v", data[0])
+	}
+
+	return p, nil
+}
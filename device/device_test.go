@@ -0,0 +1,110 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingTransport is a transport whose reports block until released,
+// recording whether it was closed while a report was in progress.
+type blockingTransport struct {
+	entered chan struct{}
+	release chan struct{}
+
+	mu         sync.Mutex
+	closes     int
+	usedClosed bool
+}
+
+func (self *blockingTransport) SendFeatureReport(data []byte) (int, error) {
+	self.entered <- struct{}{}
+	<-self.release
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.closes > 0 {
+		self.usedClosed = true
+	}
+	return len(data), nil
+}
+
+func (self *blockingTransport) GetFeatureReport(reportId byte, size int) ([]byte, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (self *blockingTransport) Close() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.closes++
+}
+
+func TestCloseWaitsForReports(t *testing.T) {
+	t.Setenv(ExperimentalEnv, "1")
+
+	tr := &blockingTransport{entered: make(chan struct{}), release: make(chan struct{})}
+	dev := &Device{t: tr}
+
+	written := make(chan error)
+	go func() {
+		written <- dev.WriteFeatureReport([]byte{0x02, 0x01, 0x01})
+	}()
+	<-tr.entered
+
+	closed := make(chan struct{})
+	go func() {
+		dev.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a report was being sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(tr.release)
+	if err := <-written; err != nil {
+		t.Errorf("WriteFeatureReport returned %v", err)
+	}
+	<-closed
+
+	if tr.usedClosed {
+		t.Error("Transport closed while a report was being sent")
+	}
+
+	if err := dev.WriteFeatureReport([]byte{0x02, 0x01, 0x01}); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteFeatureReport after Close returned %v, want ErrClosed", err)
+	}
+	if _, err := dev.readMemory(lightProfile1Address, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("readMemory after Close returned %v, want ErrClosed", err)
+	}
+
+	dev.Close()
+	if tr.closes != 1 {
+		t.Errorf("Transport closed %v times, want 1", tr.closes)
+	}
+}
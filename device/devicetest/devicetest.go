@@ -0,0 +1,56 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package devicetest helps testing code that uses the mouse, by playing
+// back cassettes (see device.OpenCassette) in place of it.
+package devicetest
+
+import (
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"testing"
+)
+
+// OpenCassette returns a device playing back the cassette at path. When
+// the test ends, the device is closed and the test fails if the reports
+// sent diverged from the cassette, or if some of them were never sent.
+func OpenCassette(t testing.TB, path string) *device.Device {
+	t.Helper()
+
+	c, err := device.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev, err := device.OpenCassette(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		dev.Close()
+		if err := c.Done(); err != nil {
+			t.Errorf("Cassette %v: %v", path, err)
+		}
+	})
+
+	return dev
+}
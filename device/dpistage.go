@@ -0,0 +1,68 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// The active profile is kept at 0x0040 (see setProfileReport1); the
+// active DPI stage, zero-based, is guessed to be in the byte right
+// after it. Unlike the profile, this address is not part of any
+// captured exchange with the vendor tool, which does not appear to
+// switch DPI stages at all, so using it is experimental.
+const activeDPIStageAddress = 0x0041
+
+// SetDPIStage selects one of the DPI stages of the active profile, as
+// the DPI button does, without changing the stored profile. The stage
+// has to be enabled in the profile.
+//
+// This writes to a guessed address, and returns ErrExperimental unless
+// the experimental features are enabled.
+func (self *Device) SetDPIStage(stage DPIStage) error {
+	if !stage.Valid() {
+		return fmt.Errorf("Invalid DPI stage: %v", stage)
+	}
+
+	if err := checkExperimental("Setting the DPI stage"); err != nil {
+		return err
+	}
+
+	return self.WriteFeatureReport(newMemoryWriteReport(activeDPIStageAddress, byte(stage)-1))
+}
+
+// ActiveDPIStage reads which DPI stage of the active profile is
+// currently selected. This is experimental, like SetDPIStage.
+func (self *Device) ActiveDPIStage() (DPIStage, error) {
+	data, err := self.readMemory(activeDPIStageAddress, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	s := DPIStage(data[0] + 1)
+	if !s.Valid() {
+		return 0, fmt.Errorf("Unknown active DPI stage %v", data[0])
+	}
+
+	return s, nil
+}
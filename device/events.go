@@ -0,0 +1,234 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// How often to check for the end of the context while no input
+	// reports arrive.
+	inputReadTimeout = 250 * time.Millisecond
+
+	// The firmware is not known to announce profile and DPI stage
+	// changes made with the buttons on the mouse: with
+	// EventsWithState, they are checked whenever a vendor-defined
+	// input report arrives, and otherwise this often.
+	statePollInterval = 2 * time.Second
+)
+
+// Events reports what happens on the mouse: movement, buttons and keys
+// from the input reports of all its interfaces. Input reports can only
+// be decoded when the report descriptors are known (see
+// InterfaceDescriptors).
+//
+// The channel is closed when ctx is cancelled, or when the input
+// reports can no longer be read, e.g. because the mouse was
+// disconnected.
+func (self *Device) Events(ctx context.Context) (<-chan Event, error) {
+	return self.events(ctx, nil)
+}
+
+// EventsWithState reports the same events as Events, as well as
+// changes of the active profile and DPI stage, including those made
+// with the buttons on the mouse. These are found by reading the memory
+// of the mouse, which is experimental: it returns ErrExperimental
+// unless the experimental features are enabled, and an error if the
+// active profile and DPI stage cannot be read to begin with.
+//
+// A failure to read them later is reported once as an ErrorEvent,
+// until they can be read again.
+func (self *Device) EventsWithState(ctx context.Context) (<-chan Event, error) {
+	if err := checkExperimental("Following the active profile and DPI stage"); err != nil {
+		return nil, err
+	}
+
+	profile, err := self.ActiveProfile()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the active profile: %w", err)
+	}
+
+	stage, err := self.ActiveDPIStage()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the active DPI stage: %w", err)
+	}
+
+	return self.events(ctx, &deviceState{profile, stage})
+}
+
+type deviceState struct {
+	profile ProfileID
+	stage   DPIStage
+}
+
+// events follows the input reports, and polls the device state if an
+// initial one is given.
+func (self *Device) events(ctx context.Context, state *deviceState) (<-chan Event, error) {
+	var intfs []int
+	for intf, desc := range self.descriptors {
+		if len(desc.Input) > 0 {
+			intfs = append(intfs, intf)
+		}
+	}
+	sort.Ints(intfs)
+
+	var readers []inputReader
+	for _, intf := range intfs {
+		r, err := self.b.openInput(intf)
+		if err != nil {
+			for _, r := range readers {
+				r.Close()
+			}
+			return nil, err
+		}
+		readers = append(readers, r)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan Event)
+	check := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+
+	send := func(ev Event) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for i, r := range readers {
+		wg.Add(1)
+		go func(r inputReader, dec *inputDecoder) {
+			defer wg.Done()
+			defer r.Close()
+			defer cancel()
+
+			buf := make([]byte, 1024)
+			for ctx.Err() == nil {
+				n, err := r.ReadInput(buf, inputReadTimeout)
+				if err != nil {
+					return
+				}
+				if n == 0 {
+					continue
+				}
+
+				events, vendor := dec.decode(time.Now(), buf[:n])
+				for _, ev := range events {
+					if !send(ev) {
+						return
+					}
+				}
+
+				if vendor {
+					select {
+					case check <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}(r, newInputDecoder(self.descriptors[intfs[i]]))
+	}
+
+	if state != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			self.pollState(ctx, *state, check, send)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// pollState sends the changes of the device state, when a vendor input
+// report arrives on check, or every statePollInterval.
+func (self *Device) pollState(ctx context.Context, state deviceState, check <-chan struct{}, send func(Event) bool) {
+	ticker := time.NewTicker(statePollInterval)
+	defer ticker.Stop()
+
+	// A failed read is reported only once, rather than every
+	// interval: the mouse might be busy with another tool for a
+	// while, and a disconnection is noticed by the readers anyway.
+	failing := false
+	fail := func(err error) bool {
+		if failing {
+			return true
+		}
+		failing = true
+		return send(Event{Kind: ErrorEvent, Time: time.Now(), Error: err.Error()})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-check:
+		}
+
+		p, err := self.ActiveProfile()
+		if err != nil {
+			if !fail(fmt.Errorf("Unable to read the active profile: %v", err)) {
+				return
+			}
+			continue
+		}
+
+		s, err := self.ActiveDPIStage()
+		if err != nil {
+			if !fail(fmt.Errorf("Unable to read the active DPI stage: %v", err)) {
+				return
+			}
+			continue
+		}
+		failing = false
+
+		if p != state.profile {
+			state.profile = p
+			if !send(Event{Kind: ProfileChanged, Time: time.Now(), Profile: p}) {
+				return
+			}
+		}
+
+		if s != state.stage {
+			state.stage = s
+			if !send(Event{Kind: DPIStageChanged, Time: time.Now(), DPIStage: s}) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Some features rely on parts of the protocol that were never captured
+// from the vendor tool, and are only educated guesses (e.g. reading the
+// memory of the mouse back, or the addresses written to switch DPI
+// stage). Writing to a guessed address could corrupt the configuration
+// stored in the mouse, so these features are refused unless explicitly
+// enabled.
+
+// ExperimentalEnv is the environment variable that, when set to any
+// value, enables the experimental features.
+const ExperimentalEnv = "ANKER_MOUSE_EXPERIMENTAL"
+
+// ErrExperimental is returned by the experimental features, when they
+// are not enabled.
+var ErrExperimental = errors.New("Experimental feature, relying on parts of the protocol that were not captured; set " + ExperimentalEnv + "=1 to enable it")
+
+var experimental atomic.Bool
+
+// EnableExperimental enables the experimental features, as if
+// ExperimentalEnv was set.
+func EnableExperimental() {
+	experimental.Store(true)
+}
+
+// Experimental reports whether the experimental features are enabled.
+func Experimental() bool {
+	return experimental.Load() || os.Getenv(ExperimentalEnv) != ""
+}
+
+func checkExperimental(what string) error {
+	if !Experimental() {
+		return fmt.Errorf("%v: %w", what, ErrExperimental)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// Keys F13 to F24, as HID keyboard usages. No keyboard in common use
+// has them, so buttons bound to them can be told apart by the host and
+// given actions there.
+const (
+	KeyF13 uint16 = 0x68
+	KeyF24 uint16 = 0x73
+)
+
+// HostKeys returns the usages of F13 to F24, in order.
+func HostKeys() []uint16 {
+	var keys []uint16
+	for k := KeyF13; k <= KeyF24; k++ {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// BindKey binds a button to send the key with the given HID keyboard
+// usage. Buttons are numbered from 1 in the order of the buttons
+// report, which is not quite the numbering of the vendor tool: the
+// fifth entry is its button 6, and the sixth is (probably) its button 5
+// (see NewButtonsProfile).
+func (self *ButtonsProfile) BindKey(button int, key uint16) error {
+	if button < 1 || button > len(self.Buttons) {
+		return fmt.Errorf("Invalid button %v: must be between 1 and %v", button, len(self.Buttons))
+	}
+
+	self.Buttons[button-1] = ButtonEntry{
+		EventId: EventSingleKey,
+		KeyId:   key,
+	}
+
+	return nil
+}
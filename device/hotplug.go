@@ -0,0 +1,56 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+	"strings"
+)
+
+type HotplugEvent int
+
+const (
+	DeviceConnected HotplugEvent = iota
+	DeviceDisconnected
+)
+
+func (self HotplugEvent) String() string {
+	switch self {
+	case DeviceConnected:
+		return "connected"
+	case DeviceDisconnected:
+		return "disconnected"
+	}
+
+	return fmt.Sprintf("HotplugEvent(%d)", int(self))
+}
+
+// hidDeviceName is the prefix of the kernel name of the HID devices
+// created for the mouse interfaces (bus:vendor:product.instance).
+var hidDeviceName = fmt.Sprintf("0003:%04X:%04X.", HoltekVendorId, AnkerMouseDeviceId)
+
+// isMouseHidraw reports whether the sysfs path of a hidraw node belongs
+// to the mouse.
+func isMouseHidraw(devpath string) bool {
+	return strings.Contains(strings.ToUpper(devpath), hidDeviceName)
+}
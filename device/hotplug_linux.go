@@ -0,0 +1,186 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package device
+
+import (
+	"bytes"
+	"context"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hotplugSettleTime is how long to wait after the last hidraw node of
+// the mouse appeared before reporting it as connected, so that all of
+// its interfaces are available, and udev had a chance to set up their
+// permissions.
+const hotplugSettleTime = time.Second
+
+type uevent struct {
+	action    string
+	devpath   string
+	subsystem string
+}
+
+func parseUevent(msg []byte) *uevent {
+	ev := new(uevent)
+	for _, field := range bytes.Split(msg, []byte{0}) {
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "ACTION":
+			ev.action = kv[1]
+		case "DEVPATH":
+			ev.devpath = kv[1]
+		case "SUBSYSTEM":
+			ev.subsystem = kv[1]
+		}
+	}
+
+	return ev
+}
+
+// Watch reports every time the mouse is connected or disconnected,
+// based on the kernel uevents for its hidraw nodes. The first event
+// sent reflects whether the mouse is connected when Watch is called.
+// The channel is closed when ctx is cancelled.
+func Watch(ctx context.Context) (<-chan HotplugEvent, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: 1, // Kernel events, as opposed to udev's.
+	})
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// Going through os.File makes the reads interruptible by Close.
+	sock := os.NewFile(uintptr(fd), "uevent")
+
+	uevents := make(chan *uevent)
+	go func() {
+		defer close(uevents)
+
+		buf := make([]byte, 16384)
+		for {
+			n, err := sock.Read(buf)
+			if err != nil {
+				return
+			}
+
+			ev := parseUevent(buf[:n])
+			if ev.subsystem != "hidraw" || !isMouseHidraw(ev.devpath) {
+				continue
+			}
+
+			select {
+			case uevents <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan HotplugEvent)
+	go func() {
+		defer close(out)
+		defer sock.Close()
+
+		nodes := mouseHidrawNodes()
+		connected := len(nodes) > 0
+
+		send := func(ev HotplugEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		initial := DeviceDisconnected
+		if connected {
+			initial = DeviceConnected
+		}
+		if !send(initial) {
+			return
+		}
+
+		settle := time.NewTimer(0)
+		<-settle.C
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case ev, ok := <-uevents:
+				if !ok {
+					return
+				}
+
+				name := filepath.Base(ev.devpath)
+				switch ev.action {
+				case "add":
+					nodes[name] = true
+					if !connected {
+						settle.Reset(hotplugSettleTime)
+					}
+				case "remove":
+					delete(nodes, name)
+					if len(nodes) == 0 {
+						settle.Stop()
+						if connected {
+							connected = false
+							if !send(DeviceDisconnected) {
+								return
+							}
+						}
+					}
+				}
+
+			case <-settle.C:
+				if len(nodes) > 0 && !connected {
+					connected = true
+					if !send(DeviceConnected) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
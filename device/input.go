@@ -0,0 +1,302 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type EventKind int
+
+const (
+	// Relative movement, in sensor counts, in X and Y.
+	MotionEvent EventKind = iota
+	// Wheel movement, in detents: vertical in Y, horizontal in X.
+	WheelEvent
+	// A mouse button, numbered from 1, pressed or released.
+	ButtonEvent
+	// A key, identified by its HID usage, pressed or released; the
+	// mouse sends these for buttons bound with EventSingleKey.
+	KeyEvent
+	// The active profile changed, e.g. with the button on the mouse.
+	ProfileChanged
+	// The active DPI stage changed.
+	DPIStageChanged
+	// The state of the mouse could not be read, with the reason in
+	// Error.
+	ErrorEvent
+)
+
+func (self EventKind) String() string {
+	switch self {
+	case MotionEvent:
+		return "motion"
+	case WheelEvent:
+		return "wheel"
+	case ButtonEvent:
+		return "button"
+	case KeyEvent:
+		return "key"
+	case ProfileChanged:
+		return "profile"
+	case DPIStageChanged:
+		return "dpi_stage"
+	case ErrorEvent:
+		return "error"
+	}
+
+	return fmt.Sprintf("EventKind(%d)", int(self))
+}
+
+func (self EventKind) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+func (self *EventKind) UnmarshalText(text []byte) error {
+	for k := MotionEvent; k <= ErrorEvent; k++ {
+		if k.String() == string(text) {
+			*self = k
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown event kind %q", text)
+}
+
+// Event is something that happened on the mouse. Only the fields
+// relevant to its Kind are set.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+
+	X int `json:"x,omitempty"`
+	Y int `json:"y,omitempty"`
+
+	Button  int    `json:"button,omitempty"`
+	Usage   uint32 `json:"usage,omitempty"` // Usage page in the high 16 bits, for buttons and keys.
+	Pressed bool   `json:"pressed,omitempty"`
+
+	Profile  ProfileID `json:"profile,omitempty"`
+	DPIStage DPIStage  `json:"dpi_stage,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+func (self Event) String() string {
+	switch self.Kind {
+	case MotionEvent, WheelEvent:
+		return fmt.Sprintf("%v %+d %+d", self.Kind, self.X, self.Y)
+	case ButtonEvent:
+		return fmt.Sprintf("%v %v %v", self.Kind, self.Button, pressedString(self.Pressed))
+	case KeyEvent:
+		return fmt.Sprintf("%v %v %v", self.Kind, UsageName(self.Usage), pressedString(self.Pressed))
+	case ProfileChanged:
+		return fmt.Sprintf("%v %v", self.Kind, self.Profile)
+	case DPIStageChanged:
+		return fmt.Sprintf("%v %v", self.Kind, self.DPIStage)
+	case ErrorEvent:
+		return fmt.Sprintf("%v %v", self.Kind, self.Error)
+	}
+
+	return self.Kind.String()
+}
+
+func pressedString(pressed bool) string {
+	if pressed {
+		return "pressed"
+	}
+	return "released"
+}
+
+// Usages from the HID Usage Tables.
+const (
+	usagePageGenericDesktop = 0x01
+	usagePageKeyboard       = 0x07
+	usagePageButton         = 0x09
+	usagePageConsumer       = 0x0c
+	usagePageVendor         = 0xff00 // and above
+
+	usageX     = usagePageGenericDesktop<<16 | 0x30
+	usageY     = usagePageGenericDesktop<<16 | 0x31
+	usageWheel = usagePageGenericDesktop<<16 | 0x38
+	usageACPan = usagePageConsumer<<16 | 0x238
+)
+
+// inputDecoder turns the input reports of one interface into events.
+// It keeps track of the buttons and keys held down, so that only
+// changes are reported.
+type inputDecoder struct {
+	desc    *ReportDescriptor
+	pressed map[uint32]bool
+}
+
+func newInputDecoder(desc *ReportDescriptor) *inputDecoder {
+	return &inputDecoder{
+		desc:    desc,
+		pressed: make(map[uint32]bool),
+	}
+}
+
+func extractBits(data []byte, offset, size uint32, signed bool) int32 {
+	var v uint32
+	for i := uint32(0); i < size; i++ {
+		bit := offset + i
+		if int(bit/8) >= len(data) {
+			break
+		}
+		if data[bit/8]&(1<<(bit%8)) != 0 {
+			v |= 1 << i
+		}
+	}
+
+	if signed && size > 0 && size < 32 && v&(1<<(size-1)) != 0 {
+		v |= ^uint32(0) << size
+	}
+
+	return int32(v)
+}
+
+// decode returns the events in the report, and whether the report
+// was not understood: either undeclared, or vendor-defined.
+func (self *inputDecoder) decode(t time.Time, report []byte) (events []Event, vendor bool) {
+	var id byte
+	data := report
+	if self.desc.hasReportIds {
+		if len(report) == 0 {
+			return nil, true
+		}
+		id, data = report[0], report[1:]
+	}
+
+	fields, ok := self.desc.inputFields[id]
+	if !ok {
+		return nil, true
+	}
+
+	var motion, wheel Event
+	down := make(map[uint32]bool)
+
+	for _, f := range fields {
+		if f.usagePage() >= usagePageVendor {
+			vendor = true
+			continue
+		}
+
+		v := extractBits(data, f.offset, f.size, f.signed)
+
+		if f.array {
+			if usage := f.usage + uint32(v-f.arrayMin); v >= f.arrayMin && usage&0xffff != 0 {
+				down[usage] = true
+			}
+			continue
+		}
+
+		switch f.usage {
+		case usageX:
+			motion.X = int(v)
+		case usageY:
+			motion.Y = int(v)
+		case usageWheel:
+			wheel.Y = int(v)
+		case usageACPan:
+			wheel.X = int(v)
+		default:
+			if f.size == 1 && v != 0 {
+				down[f.usage] = true
+			} else if f.size == 1 {
+				down[f.usage] = false
+			}
+		}
+	}
+
+	if motion.X != 0 || motion.Y != 0 {
+		motion.Kind, motion.Time = MotionEvent, t
+		events = append(events, motion)
+	}
+	if wheel.X != 0 || wheel.Y != 0 {
+		wheel.Kind, wheel.Time = WheelEvent, t
+		events = append(events, wheel)
+	}
+
+	// Only the usages declared by this report can be released by it.
+	declared := make(map[uint32]bool)
+	for _, f := range fields {
+		if f.array {
+			for u := range self.pressed {
+				if u>>16 == f.usage>>16 {
+					declared[u] = true
+				}
+			}
+		} else {
+			declared[f.usage] = true
+		}
+	}
+
+	for _, u := range sortedUsages(declared) {
+		if self.pressed[u] && !down[u] {
+			delete(self.pressed, u)
+			events = append(events, usageEvent(t, u, false))
+		}
+	}
+	for _, u := range sortedUsages(down) {
+		if down[u] && !self.pressed[u] {
+			self.pressed[u] = true
+			events = append(events, usageEvent(t, u, true))
+		}
+	}
+
+	return events, vendor
+}
+
+func sortedUsages(m map[uint32]bool) []uint32 {
+	var usages []uint32
+	for u := range m {
+		usages = append(usages, u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i] < usages[j]
+	})
+
+	return usages
+}
+
+func usageEvent(t time.Time, usage uint32, pressed bool) Event {
+	if usage>>16 == usagePageButton {
+		return Event{
+			Kind:    ButtonEvent,
+			Time:    t,
+			Button:  int(usage & 0xffff),
+			Usage:   usage,
+			Pressed: pressed,
+		}
+	}
+
+	return Event{
+		Kind:    KeyEvent,
+		Time:    t,
+		Usage:   usage,
+		Pressed: pressed,
+	}
+}
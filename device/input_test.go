@@ -0,0 +1,151 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testInputDescriptor declares a mouse (report 1), keys (report 2) and a
+// vendor-defined report (3). The usages of X and Y are given before the
+// usage page they belong to, which applies from the main item.
+var testInputDescriptor = []byte{
+	0x85, 0x01, // Report ID (1)
+	0x05, 0x09, // Usage Page (Button)
+	0x19, 0x01, // Usage Minimum (1)
+	0x29, 0x03, // Usage Maximum (3)
+	0x15, 0x00, // Logical Minimum (0)
+	0x25, 0x01, // Logical Maximum (1)
+	0x75, 0x01, // Report Size (1)
+	0x95, 0x03, // Report Count (3)
+	0x81, 0x02, // Input (Data, Variable, Absolute)
+	0x75, 0x05, // Report Size (5)
+	0x95, 0x01, // Report Count (1)
+	0x81, 0x01, // Input (Constant)
+	0x09, 0x30, // Usage (X)
+	0x09, 0x31, // Usage (Y)
+	0x05, 0x01, // Usage Page (Generic Desktop)
+	0x15, 0x81, // Logical Minimum (-127)
+	0x25, 0x7f, // Logical Maximum (127)
+	0x75, 0x08, // Report Size (8)
+	0x95, 0x02, // Report Count (2)
+	0x81, 0x06, // Input (Data, Variable, Relative)
+	0x09, 0x38, // Usage (Wheel)
+	0x95, 0x01, // Report Count (1)
+	0x81, 0x06, // Input (Data, Variable, Relative)
+	0x05, 0x0c, // Usage Page (Consumer)
+	0x0a, 0x38, 0x02, // Usage (AC Pan)
+	0x81, 0x06, // Input (Data, Variable, Relative)
+
+	0x85, 0x02, // Report ID (2)
+	0x05, 0x07, // Usage Page (Keyboard)
+	0x19, 0x00, // Usage Minimum (0)
+	0x29, 0x65, // Usage Maximum (101)
+	0x15, 0x00, // Logical Minimum (0)
+	0x25, 0x65, // Logical Maximum (101)
+	0x75, 0x08, // Report Size (8)
+	0x95, 0x02, // Report Count (2)
+	0x81, 0x00, // Input (Data, Array)
+
+	0x85, 0x03, // Report ID (3)
+	0x06, 0x00, 0xff, // Usage Page (Vendor 0xff00)
+	0x09, 0x01, // Usage (1)
+	0x95, 0x01, // Report Count (1)
+	0x81, 0x02, // Input (Data, Variable, Absolute)
+}
+
+func TestInputDecoder(t *testing.T) {
+	desc, err := ParseReportDescriptor(testInputDescriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := newInputDecoder(desc)
+
+	now := time.Now()
+	button := func(n int, pressed bool) Event {
+		return Event{Kind: ButtonEvent, Time: now, Button: n, Usage: usagePageButton<<16 | uint32(n), Pressed: pressed}
+	}
+	key := func(usage uint32, pressed bool) Event {
+		return Event{Kind: KeyEvent, Time: now, Usage: usagePageKeyboard<<16 | usage, Pressed: pressed}
+	}
+
+	// The decoder remembers the buttons and keys held, so the
+	// reports are decoded in order.
+	steps := []struct {
+		name       string
+		report     []byte
+		want       []Event
+		wantVendor bool
+	}{
+		{"press", []byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00}, []Event{button(1, true)}, false},
+		{"repeat", []byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00}, nil, false},
+		{"motion", []byte{0x01, 0x01, 0x05, 0xfb, 0x00, 0x00}, []Event{{Kind: MotionEvent, Time: now, X: 5, Y: -5}}, false},
+		{"chord", []byte{0x01, 0x06, 0x00, 0x00, 0x00, 0x00}, []Event{button(1, false), button(2, true), button(3, true)}, false},
+		{"wheel", []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0xff}, []Event{{Kind: WheelEvent, Time: now, X: -1, Y: 1}, button(2, false), button(3, false)}, false},
+		{"key", []byte{0x02, 0x04, 0x00}, []Event{key(0x04, true)}, false},
+		{"keys", []byte{0x02, 0x05, 0x04}, []Event{key(0x05, true)}, false},
+		{"key release", []byte{0x02, 0x00, 0x00}, []Event{key(0x04, false), key(0x05, false)}, false},
+		{"vendor", []byte{0x03, 0x2a}, nil, true},
+		{"undeclared", []byte{0x09, 0x00}, nil, true},
+		{"empty", []byte{}, nil, true},
+	}
+
+	for _, step := range steps {
+		events, vendor := dec.decode(now, step.report)
+		if !reflect.DeepEqual(events, step.want) {
+			t.Errorf("%v: decoded %v, want %v", step.name, events, step.want)
+		}
+		if vendor != step.wantVendor {
+			t.Errorf("%v: vendor %v, want %v", step.name, vendor, step.wantVendor)
+		}
+	}
+}
+
+func TestExtractBits(t *testing.T) {
+	data := []byte{0xa5, 0xf0, 0x80}
+
+	tests := []struct {
+		offset, size uint32
+		signed       bool
+		want         int32
+	}{
+		{0, 1, false, 1},
+		{1, 1, false, 0},
+		{0, 8, false, 0xa5},
+		{0, 8, true, -91},
+		{4, 8, false, 0x0a},
+		{12, 4, true, -1},
+		{8, 16, false, 0x80f0},
+		{8, 16, true, -32528},
+		// Past the end of the report, the bits are zero.
+		{16, 16, false, 0x80},
+	}
+
+	for _, tt := range tests {
+		if got := extractBits(data, tt.offset, tt.size, tt.signed); got != tt.want {
+			t.Errorf("extractBits(%v, %v, %v) = %v, want %v", tt.offset, tt.size, tt.signed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LintIssue describes a configuration that is valid, but would leave
+// the mouse hard or impossible to use.
+type LintIssue struct {
+	Profile ProfileID // 0 if the issue is not specific to a profile.
+	Message string
+}
+
+func (self LintIssue) String() string {
+	if self.Profile == 0 {
+		return self.Message
+	}
+
+	return fmt.Sprintf("Profile %v: %v", self.Profile, self.Message)
+}
+
+// LintError is returned when refusing to write a configuration that
+// has lint issues.
+type LintError struct {
+	Issues []LintIssue
+}
+
+func (self *LintError) Error() string {
+	var msgs []string
+	for _, i := range self.Issues {
+		msgs = append(msgs, i.String())
+	}
+
+	return "Refusing to write a configuration that could lock the user out: " + strings.Join(msgs, "; ")
+}
+
+func (self *ButtonsProfile) hasEvent(eventId byte) bool {
+	for _, b := range self.Buttons {
+		if b.EventId == eventId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *DPIProfile) hasEnabledLevel() bool {
+	for _, e := range self.DPI {
+		if e.Enabled != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lint looks for configurations that would leave the mouse without a
+// primary click or a usable resolution, or stuck in one profile.
+func (self *Config) Lint() []LintIssue {
+	var issues []LintIssue
+
+	switchable := false
+	for i, p := range self.Profiles {
+		profile := ProfileID(i + 1)
+		if p == nil || p.ButtonsProfile == nil || p.DPIProfile == nil {
+			// Reported by Validate.
+			continue
+		}
+
+		if !p.ButtonsProfile.hasEvent(EventLeftClick) {
+			issues = append(issues, LintIssue{profile, "no button is bound to left click"})
+		}
+
+		if !p.DPIProfile.hasEnabledLevel() {
+			issues = append(issues, LintIssue{profile, "all DPI levels are disabled"})
+		}
+
+		if p.ButtonsProfile.hasEvent(EventDPISwitch) {
+			switchable = true
+		}
+	}
+
+	if !switchable {
+		issues = append(issues, LintIssue{0, "no button is bound to the DPI/profile switch in either profile"})
+	}
+
+	return issues
+}
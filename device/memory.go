@@ -0,0 +1,148 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Most of the reports with ID 2 access the device memory, with the
+// same layout as the profile selection and light profile reports:
+//
+//	[0x02] [command] [address, LE] [length, LE] [0xFA 0xFA] [data...]
+//
+// e.g. the configuration preamble reads one byte at 0x0040, which is
+// where setProfileReport1 writes the active profile.
+//
+// The read commands are part of the captured preamble, but their
+// answers were not captured: the vendor tool is not known to fetch the
+// feature report back at all. The format of the answer expected by
+// readMemory is an assumption, so everything built on it (the active
+// profile and DPI stage, and the polling rate) is experimental.
+
+const (
+	memoryCommandWrite = 0x02
+	memoryCommandRead  = 0x03
+
+	memoryMagic = 0xFAFA
+
+	// Only as much data as fits in a report with ID 2 is supported.
+	maxMemoryData = 8
+)
+
+type memoryReport struct {
+	ReportId byte // 0x02
+	Command  byte
+	Address  uint16
+	Length   uint16
+	Magic    uint16 // 0xFAFA
+	Data     [maxMemoryData]byte
+}
+
+func newMemoryWriteReport(address uint16, data ...byte) *memoryReport {
+	r := &memoryReport{
+		ReportId: 0x02,
+		Command:  memoryCommandWrite,
+		Address:  address,
+		Length:   uint16(len(data)),
+		Magic:    memoryMagic,
+	}
+	copy(r.Data[:], data)
+
+	return r
+}
+
+func newMemoryReadReport(address uint16, length int) *memoryReport {
+	return &memoryReport{
+		ReportId: 0x02,
+		Command:  memoryCommandRead,
+		Address:  address,
+		Length:   uint16(length),
+		Magic:    memoryMagic,
+	}
+}
+
+func reportBytes(report interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.LittleEndian, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readMemory sends a read command, and reads the result back as a
+// feature report. The answer is expected to repeat the command,
+// address and length before the data, and is refused otherwise. It
+// returns ErrExperimental unless the experimental features are
+// enabled.
+func (self *Device) readMemory(address uint16, length int) ([]byte, error) {
+	if length > maxMemoryData {
+		return nil, fmt.Errorf("Cannot read %v bytes at once", length)
+	}
+
+	if err := checkExperimental(fmt.Sprintf("Reading address %#04x", address)); err != nil {
+		return nil, err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if err := self.writeFeatureReport(newMemoryReadReport(address, length)); err != nil {
+		return nil, err
+	}
+
+	t, _, err := self.transportFor(0x02)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.GetFeatureReport(0x02, binary.Size(memoryReport{})-1)
+	self.notify(&ReportInfo{
+		Time:      start,
+		Direction: ReportReceived,
+		Type:      ReportType(resp),
+		Data:      resp,
+		Duration:  time.Since(start),
+		Err:       err,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var r memoryReport
+	if err := binary.Read(bytes.NewReader(resp), binary.LittleEndian, &r); err != nil {
+		return nil, fmt.Errorf("Short answer reading address %#04x: %v", address, err)
+	}
+
+	if r.Command != memoryCommandRead || r.Address != address || int(r.Length) != length {
+		return nil, fmt.Errorf("Unexpected answer reading address %#04x: % x", address, resp)
+	}
+
+	return r.Data[:length], nil
+}
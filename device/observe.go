@@ -0,0 +1,156 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidReport is wrapped by the errors for reports that do not
+// match the report descriptor of the mouse, and were not sent.
+var ErrInvalidReport = errors.New("Invalid feature report")
+
+type ReportDirection int
+
+const (
+	ReportSent ReportDirection = iota
+	ReportReceived
+)
+
+func (self ReportDirection) String() string {
+	switch self {
+	case ReportSent:
+		return "sent"
+	case ReportReceived:
+		return "received"
+	}
+
+	return fmt.Sprintf("ReportDirection(%d)", int(self))
+}
+
+func (self ReportDirection) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+func (self *ReportDirection) UnmarshalText(text []byte) error {
+	for d := ReportSent; d <= ReportReceived; d++ {
+		if d.String() == string(text) {
+			*self = d
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown report direction %q", text)
+}
+
+// ReportInfo describes a feature report exchanged with the mouse.
+type ReportInfo struct {
+	Time      time.Time
+	Direction ReportDirection
+	Type      string // See ReportType.
+	Data      []byte // Including the report ID.
+	// The value passed to WriteFeatureReport, e.g. a *SetLightReport,
+	// if it was not already serialised.
+	Report   interface{}
+	Duration time.Duration
+	Err      error
+}
+
+// Observe registers a function to call for every feature report sent
+// to or received from the mouse, e.g. to trace or measure them. It is
+// called with the device locked, so it should return quickly, and not
+// use the device.
+func (self *Device) Observe(f func(*ReportInfo)) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.observers = append(self.observers, f)
+}
+
+func (self *Device) notify(info *ReportInfo) {
+	for _, f := range self.observers {
+		f(info)
+	}
+}
+
+// Addresses of the LightProfile of each profile, as written in its
+// Constant1 and ProfileId fields.
+const (
+	lightProfile1Address = 0x0881
+	lightProfile2Address = 0x1181
+)
+
+// ReportType names the kind of a feature report from its content: e.g.
+// "light" for SetLightReport, "set_profile", "buttons_profile" or
+// "memory_read".
+func ReportType(data []byte) string {
+	if len(data) < 2 {
+		return "empty"
+	}
+
+	switch data[0] {
+	case 0x03:
+		// The DPI profiles are written at 0x2000 and 0x2009; the
+		// preamble of the configuration also writes other blocks.
+		if len(data) > 3 && data[3] == 0x20 {
+			return "dpi_profile"
+		}
+		return "memory_write"
+	case 0x04:
+		return "buttons_profile"
+	case 0x02:
+		// Handled below.
+	default:
+		return fmt.Sprintf("report_%d", data[0])
+	}
+
+	switch data[1] {
+	case 0x01:
+		return "activate"
+	case memoryCommandRead:
+		return "memory_read"
+	case 0x04:
+		return "light"
+	case memoryCommandWrite:
+		if len(data) < 4 {
+			return "memory_write"
+		}
+
+		switch binary.LittleEndian.Uint16(data[2:]) {
+		case activeProfileAddress:
+			return "set_profile"
+		case activeDPIStageAddress:
+			return "set_dpi_stage"
+		case pollingRateAddress:
+			return "polling_rate"
+		case lightProfile1Address, lightProfile2Address:
+			return "light_profile"
+		}
+		return "memory_write"
+	}
+
+	return fmt.Sprintf("command_%d", data[1])
+}
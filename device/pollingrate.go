@@ -0,0 +1,104 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// PollingRate is the USB report rate of the mouse, in Hz.
+type PollingRate int
+
+const (
+	PollingRate125Hz  PollingRate = 125
+	PollingRate250Hz  PollingRate = 250
+	PollingRate500Hz  PollingRate = 500
+	PollingRate1000Hz PollingRate = 1000
+
+	// DefaultPollingRate is the rate written by the captured vendor
+	// configuration.
+	DefaultPollingRate = PollingRate500Hz
+)
+
+// The configuration captured from the vendor tool writes a single byte,
+// 2, at this address, and it was annotated as the polling rate when
+// captured, set to 500 Hz. No other value was captured: the other rates
+// are written as their interval in milliseconds, which is a guess
+// consistent with that single value, so they are experimental.
+const pollingRateAddress = 0x0045
+
+func NewPollingRate(hz int) (PollingRate, error) {
+	r := PollingRate(hz)
+	if !r.Valid() {
+		return 0, fmt.Errorf("Invalid polling rate %v: must be one of 125, 250, 500 or 1000 Hz", hz)
+	}
+
+	return r, nil
+}
+
+func (self PollingRate) Valid() bool {
+	switch self {
+	case PollingRate125Hz, PollingRate250Hz, PollingRate500Hz, PollingRate1000Hz:
+		return true
+	}
+
+	return false
+}
+
+// captured reports whether the encoding of the rate was captured from
+// the vendor tool.
+func (self PollingRate) captured() bool {
+	return self == DefaultPollingRate
+}
+
+func (self PollingRate) interval() byte {
+	return byte(1000 / int(self))
+}
+
+func (self PollingRate) String() string {
+	if self == 0 {
+		return "unset"
+	}
+
+	return fmt.Sprintf("%d Hz", int(self))
+}
+
+// PollingRate reads the polling rate currently configured in the mouse.
+// Reading the memory of the mouse is experimental (see readMemory).
+func (self *Device) PollingRate() (PollingRate, error) {
+	data, err := self.readMemory(pollingRateAddress, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	if data[0] == 0 {
+		return 0, fmt.Errorf("Invalid polling interval 0")
+	}
+
+	r := PollingRate(1000 / int(data[0]))
+	if !r.Valid() || r.interval() != data[0] {
+		return 0, fmt.Errorf("Unknown polling interval %v", data[0])
+	}
+
+	return r, nil
+}
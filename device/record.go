@@ -0,0 +1,118 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The configuration cannot be read back from the mouse, so the tools
+// record the last configuration they wrote in the user's configuration
+// directory instead, to be used as a backup. Writing a configuration
+// does not record it by itself: it is up to the callers to call
+// RecordConfig once they are done.
+
+// ConfigDir returns the directory the tools keep their files in,
+// e.g. ~/.config/anker-mouse-tool.
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "anker-mouse-tool"), nil
+}
+
+func lastConfigPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "last-config.json"), nil
+}
+
+// LoadLastConfig returns the configuration last written to the mouse by
+// these tools. The error satisfies os.IsNotExist if there is none.
+func LoadLastConfig() (*Config, error) {
+	path, err := lastConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(Config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("Invalid recorded configuration %v: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// RecordConfig merges the given profiles of a configuration just
+// written into the recorded one, or both profiles if none are given. If
+// nothing was recorded before, the other profile is recorded with its
+// defaults. A recorded configuration that cannot be loaded is left
+// alone, and its error returned.
+func RecordConfig(cfg *Config, profiles ...ProfileID) error {
+	if len(profiles) == 0 {
+		profiles = []ProfileID{Profile1, Profile2}
+	}
+
+	last, err := LoadLastConfig()
+	if os.IsNotExist(err) {
+		last = NewConfig()
+	} else if err != nil {
+		return err
+	}
+
+	for _, p := range profiles {
+		last.Profiles[p.index()] = cfg.Profiles[p.index()]
+	}
+	if cfg.PollingRate != 0 {
+		last.PollingRate = cfg.PollingRate
+	}
+
+	data, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path, err := lastConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
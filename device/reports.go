@@ -28,12 +28,12 @@ type SetLightReport struct {
 	InverseRed   byte
 	InverseGreen byte
 	InverseBlue  byte
-	Brightness   byte
-	BreathSpeed  byte
+	Brightness   Brightness
+	BreathSpeed  BreathSpeed
 	unknown      [9]byte // All zeroes.
 }
 
-func newSetLightReport(r, g, b, brightness, breathSpeed byte) *SetLightReport {
+func newSetLightReport(r, g, b byte, brightness Brightness, breathSpeed BreathSpeed) *SetLightReport {
 	return &SetLightReport{
 		reportId:     0x02,
 		internalId:   0x04,
@@ -52,6 +52,9 @@ type setProfileReport1 struct {
 	unknown   [7]byte // All zeroes.
 }
 
+// setProfileReport1 writes the active profile at this address.
+const activeProfileAddress = 0x0040
+
 var setProfile1Constant1 = [7]byte{0x02, 0x40, 0x00, 0x01, 0x00, 0xFA, 0xFA}
 
 type setProfileReport2 struct {
@@ -61,7 +64,7 @@ type setProfileReport2 struct {
 	unknown   [12]byte // All zeroes.
 }
 
-func newSetProfileReports(byte profileId) (*setProfileReport1, *setProfileReport2) {
+func newSetProfileReports(profileId byte) (*setProfileReport1, *setProfileReport2) {
 	r1 := setProfileReport1{
 		reportId:  0x02,
 		constant1: setProfile1Constant1,
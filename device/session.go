@@ -0,0 +1,227 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sessions are the feature reports exchanged with the mouse, stored one
+// per line as JSON objects, e.g.:
+//
+//	{"time":"2016-10-02T14:23:01.204518+01:00","direction":"sent","type":"light","data":"0204fefdfc0101000000000000000000","duration":"812µs"}
+//
+// so that a problem can be reported by sending the session instead of
+// describing it, and the session replayed on another mouse.
+
+const (
+	// TraceEnv is the environment variable that, when set to any
+	// value, makes Open log every feature report to the standard
+	// error.
+	TraceEnv = "ANKER_MOUSE_TRACE"
+	// SessionEnv is the environment variable that makes Open append
+	// every feature report to the session file it names.
+	SessionEnv = "ANKER_MOUSE_SESSION"
+)
+
+type sessionLine struct {
+	Time      time.Time       `json:"time"`
+	Direction ReportDirection `json:"direction"`
+	Type      string          `json:"type"`
+	Data      string          `json:"data"`
+	Duration  string          `json:"duration,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// WriteSessionReport appends a report to a session.
+func WriteSessionReport(w io.Writer, info *ReportInfo) error {
+	l := sessionLine{
+		Time:      info.Time,
+		Direction: info.Direction,
+		Type:      info.Type,
+		Data:      hex.EncodeToString(info.Data),
+	}
+	if info.Duration != 0 {
+		l.Duration = info.Duration.String()
+	}
+	if info.Err != nil {
+		l.Error = info.Err.Error()
+	}
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadSession reads all the reports of a session. The errors recorded
+// are only available as text, except that those of the reports refused
+// before being sent still wrap ErrInvalidReport; the Report field is
+// never set.
+func ReadSession(r io.Reader) ([]*ReportInfo, error) {
+	var reports []*ReportInfo
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var l sessionLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("Invalid report at line %v: %v", line, err)
+		}
+
+		info := &ReportInfo{
+			Time:      l.Time,
+			Direction: l.Direction,
+			Type:      l.Type,
+		}
+
+		var err error
+		if info.Data, err = hex.DecodeString(l.Data); err != nil {
+			return nil, fmt.Errorf("Invalid report data at line %v: %v", line, err)
+		}
+		if l.Duration != "" {
+			if info.Duration, err = time.ParseDuration(l.Duration); err != nil {
+				return nil, fmt.Errorf("Invalid report duration at line %v: %v", line, err)
+			}
+		}
+		if msg, ok := strings.CutPrefix(l.Error, ErrInvalidReport.Error()); ok {
+			info.Err = fmt.Errorf("%w%v", ErrInvalidReport, msg)
+		} else if l.Error != "" {
+			info.Err = errors.New(l.Error)
+		}
+
+		reports = append(reports, info)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// Trace logs every feature report exchanged with the mouse, with its
+// type and content, at debug level (or warning level if it failed).
+func (self *Device) Trace(logger *slog.Logger) {
+	self.Observe(func(info *ReportInfo) {
+		level := slog.LevelDebug
+		if info.Err != nil {
+			level = slog.LevelWarn
+		}
+
+		ctx := context.Background()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		r := slog.NewRecord(info.Time, level, "Feature report", 0)
+		r.AddAttrs(
+			slog.String("direction", info.Direction.String()),
+			slog.String("type", info.Type),
+			slog.String("data", fmt.Sprintf("% x", info.Data)),
+			slog.Duration("duration", info.Duration),
+		)
+		if info.Err != nil {
+			r.AddAttrs(slog.Any("err", info.Err))
+		}
+
+		logger.Handler().Handle(ctx, r)
+	})
+}
+
+// RecordSession appends every feature report exchanged with the mouse
+// to w. Recording stops at the first error writing to w, which is
+// logged.
+func (self *Device) RecordSession(w io.Writer) {
+	failed := false
+
+	self.Observe(func(info *ReportInfo) {
+		if failed {
+			return
+		}
+
+		if err := WriteSessionReport(w, info); err != nil {
+			slog.Error("Unable to record the session", "err", err)
+			failed = true
+		}
+	})
+}
+
+// traceFromEnv enables the tracing and recording requested through
+// TraceEnv and SessionEnv.
+func (self *Device) traceFromEnv() error {
+	if os.Getenv(TraceEnv) != "" {
+		self.Trace(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+
+	if path := os.Getenv(SessionEnv); path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+
+		self.files = append(self.files, f)
+		self.RecordSession(f)
+	}
+
+	return nil
+}
+
+// Replay sends to the mouse every report sent in a session, in order,
+// including the memory reads of the configuration preamble and the
+// reports that failed. Only the reports refused before reaching the
+// mouse (ErrInvalidReport) are left out, as they were never sent, as
+// well as those skip returns true for, if not nil. The reports are sent
+// as recorded, without checking whether they could lock the user out.
+func (self *Device) Replay(session []*ReportInfo, skip func(*ReportInfo) bool) error {
+	for _, info := range session {
+		if info.Direction != ReportSent || errors.Is(info.Err, ErrInvalidReport) {
+			continue
+		}
+		if skip != nil && skip(info) {
+			continue
+		}
+
+		if err := self.WriteFeatureReport(info.Data); err != nil {
+			return fmt.Errorf("Error replaying the %v report of %v: %w", info.Type, info.Time.Format(time.RFC3339Nano), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sendRecorder is a transport that records the reports sent to it.
+type sendRecorder struct {
+	sent [][]byte
+}
+
+func (self *sendRecorder) SendFeatureReport(data []byte) (int, error) {
+	self.sent = append(self.sent, append([]byte(nil), data...))
+	return len(data), nil
+}
+
+func (self *sendRecorder) GetFeatureReport(reportId byte, size int) ([]byte, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (self *sendRecorder) Close() {}
+
+const testSession = `{"time":"2016-10-02T14:23:01.1+01:00","direction":"sent","type":"memory_read","data":"020340000100fafa0000000000000000"}
+{"time":"2016-10-02T14:23:01.2+01:00","direction":"received","type":"memory_read","data":"020340000100fafa0000000000000000"}
+{"time":"2016-10-02T14:23:01.3+01:00","direction":"sent","type":"memory_read","data":"02034800200000000000000000000000"}
+{"time":"2016-10-02T14:23:01.4+01:00","direction":"sent","type":"light","data":"0204fefdfc0101000000000000000000","error":"timeout"}
+{"time":"2016-10-02T14:23:01.5+01:00","direction":"sent","type":"report_9","data":"09","error":"Invalid feature report: Feature report 9 is not declared by any interface of the device"}
+{"time":"2016-10-02T14:23:01.6+01:00","direction":"sent","type":"activate","data":"02010100000000000000000000000000"}
+`
+
+func TestReplay(t *testing.T) {
+	session, err := ReadSession(strings.NewReader(testSession))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(session[4].Err, ErrInvalidReport) {
+		t.Errorf("Refused report error %v does not wrap ErrInvalidReport", session[4].Err)
+	}
+	if errors.Is(session[3].Err, ErrInvalidReport) {
+		t.Errorf("Failed report error %v wraps ErrInvalidReport", session[3].Err)
+	}
+
+	tests := []struct {
+		name string
+		skip func(*ReportInfo) bool
+		want []int // Indexes of the session reports sent again.
+	}{
+		{"everything", nil, []int{0, 2, 3, 5}},
+		{"skipping", func(info *ReportInfo) bool { return info.Type == "light" }, []int{0, 2, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := new(sendRecorder)
+			dev := &Device{t: tr}
+
+			if err := dev.Replay(session, tt.skip); err != nil {
+				t.Fatal(err)
+			}
+
+			var want [][]byte
+			for _, i := range tt.want {
+				want = append(want, session[i].Data)
+			}
+			if !reflect.DeepEqual(tr.sent, want) {
+				t.Errorf("Sent:\n% x\nwant:\n% x", tr.sent, want)
+			}
+		})
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	session, err := ReadSession(strings.NewReader(testSession))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, info := range session {
+		if err := WriteSessionReport(&buf, info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	again, err := ReadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, session) {
+		t.Errorf("Session changed writing it back:\n%v\nwant:\n%v", again, session)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"os"
+)
+
+// LightState describes a temporary light setting, as set by SetLight.
+type LightState struct {
+	Color       colorful.HexColor `json:"color"`
+	Brightness  Brightness        `json:"brightness"`
+	BreathSpeed BreathSpeed       `json:"breath_speed"`
+}
+
+// State is a desired state for the mouse, that can be stored and
+// applied again every time the mouse is connected. Nil fields are left
+// alone.
+type State struct {
+	Light   *LightState `json:"light,omitempty"`
+	Profile *ProfileID  `json:"profile,omitempty"`
+	Config  *Config     `json:"config,omitempty"`
+
+	// Write the configuration even if it fails Config.Lint.
+	Force bool `json:"force,omitempty"`
+}
+
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(State)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Invalid state file %v: %v", path, err)
+	}
+
+	return s, nil
+}
+
+func (self *State) Save(path string) error {
+	data, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Apply writes the state to the device. The configuration is written
+// first, so that the profile and light are set on top of it.
+func (self *Device) Apply(s *State) error {
+	if s.Config != nil {
+		write := s.Config.Write
+		if s.Force {
+			write = s.Config.WriteForce
+		}
+
+		if err := write(self); err != nil {
+			return err
+		}
+	}
+
+	if s.Profile != nil {
+		if err := self.SetProfile(*s.Profile); err != nil {
+			return err
+		}
+	}
+
+	if s.Light != nil {
+		err := self.SetLight(colorful.Color(s.Light.Color), s.Light.Brightness, s.Light.BreathSpeed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
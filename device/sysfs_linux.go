@@ -0,0 +1,185 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mouseHidrawNodes returns the names of the hidraw nodes that currently
+// belong to the mouse.
+func mouseHidrawNodes() map[string]bool {
+	nodes := make(map[string]bool)
+
+	links, _ := filepath.Glob("/sys/class/hidraw/hidraw*")
+	for _, l := range links {
+		target, err := os.Readlink(l)
+		if err != nil || !isMouseHidraw(target) {
+			continue
+		}
+		nodes[filepath.Base(l)] = true
+	}
+
+	return nodes
+}
+
+type hidrawNode struct {
+	name       string // e.g. hidraw3
+	intf       int
+	raw        []byte
+	descriptor *ReportDescriptor
+}
+
+func (self *hidrawNode) path() string {
+	return filepath.Join("/dev", self.name)
+}
+
+// findHidrawNodes returns the hidraw nodes of the mouse, sorted by USB
+// interface number.
+func findHidrawNodes() ([]*hidrawNode, error) {
+	var nodes []*hidrawNode
+
+	for name := range mouseHidrawNodes() {
+		// /sys/class/hidraw/hidrawN/device is the HID device, whose
+		// parent is the USB interface.
+		hiddev, err := filepath.EvalSymlinks(filepath.Join("/sys/class/hidraw", name, "device"))
+		if err != nil {
+			return nil, err
+		}
+
+		intf, err := hidInterfaceNumber(hiddev)
+		if err != nil {
+			return nil, err
+		}
+
+		// Unlike the hidraw node, the descriptor in sysfs is readable
+		// by every user.
+		raw, err := os.ReadFile(filepath.Join(hiddev, "report_descriptor"))
+		if err != nil {
+			return nil, err
+		}
+
+		desc, err := ParseReportDescriptor(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, &hidrawNode{
+			name:       name,
+			intf:       intf,
+			raw:        raw,
+			descriptor: desc,
+		})
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].intf < nodes[j].intf
+	})
+
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i].intf == nodes[i-1].intf {
+			return nil, fmt.Errorf("Both %v and %v are interface %v", nodes[i-1].name, nodes[i].name, nodes[i].intf)
+		}
+	}
+
+	return nodes, nil
+}
+
+// hidInterfaceNumber returns the USB interface number of a HID device.
+// Virtual devices (e.g. created through uhid) have no USB interface as
+// parent, so their number is taken from the physical path, which ends
+// in /inputN as for USB devices. Without either, the number is not
+// known and an error is returned.
+func hidInterfaceNumber(hiddev string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(hiddev), "bInterfaceNumber"))
+	if err == nil {
+		intf, err := strconv.ParseInt(strings.TrimSpace(string(data)), 16, 0)
+		return int(intf), err
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	uevent, err := os.ReadFile(filepath.Join(hiddev, "uevent"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(uevent), "\n") {
+		phys, ok := strings.CutPrefix(line, "HID_PHYS=")
+		if !ok {
+			continue
+		}
+
+		i := strings.LastIndex(phys, "/input")
+		if i < 0 {
+			break
+		}
+
+		intf, err := strconv.Atoi(phys[i+len("/input"):])
+		if err != nil {
+			return 0, fmt.Errorf("Unable to find the interface number of %v: %v", hiddev, err)
+		}
+		return intf, nil
+	}
+
+	return 0, fmt.Errorf("Unable to find the interface number of %v", hiddev)
+}
+
+// InterfaceDescriptors returns the report descriptors of the mouse
+// interfaces, by USB interface number.
+func InterfaceDescriptors() (map[int]*ReportDescriptor, error) {
+	nodes, err := findHidrawNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	descs := make(map[int]*ReportDescriptor)
+	for _, n := range nodes {
+		descs[n.intf] = n.descriptor
+	}
+
+	return descs, nil
+}
+
+// RawInterfaceDescriptors returns the report descriptors of the mouse
+// interfaces as the mouse sends them, by USB interface number.
+func RawInterfaceDescriptors() (map[int][]byte, error) {
+	nodes, err := findHidrawNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	descs := make(map[int][]byte)
+	for _, n := range nodes {
+		descs[n.intf] = n.raw
+	}
+
+	return descs, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHidInterfaceNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string // Relative to the USB interface.
+		want   int
+		wantOk bool
+	}{
+		{"usb", map[string]string{"bInterfaceNumber": "0a\n", "hid/uevent": "HID_PHYS=usb-0000:00:14.0-1/input1\n"}, 10, true},
+		{"uhid", map[string]string{"hid/uevent": "HID_NAME=Virtual\nHID_PHYS=usb-virtual/input2\n"}, 2, true},
+		{"bad suffix", map[string]string{"hid/uevent": "HID_PHYS=usb-virtual/inputX\n"}, 0, false},
+		{"no input", map[string]string{"hid/uevent": "HID_PHYS=virtual\n"}, 0, false},
+		{"no phys", map[string]string{"hid/uevent": "HID_NAME=Virtual\n"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "hid"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := hidInterfaceNumber(filepath.Join(dir, "hid"))
+			if !tt.wantOk {
+				if err == nil {
+					t.Errorf("hidInterfaceNumber returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("hidInterfaceNumber returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
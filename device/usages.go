@@ -0,0 +1,102 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// Names of the HID usages the mouse can send, from the HID Usage
+// Tables.
+
+var keyboardUsageNames = map[uint16]string{
+	0x28: "Enter", 0x29: "Escape", 0x2a: "Backspace", 0x2b: "Tab", 0x2c: "Space",
+	0x2d: "-", 0x2e: "=", 0x2f: "[", 0x30: "]", 0x31: "\\", 0x32: "NonUS#",
+	0x33: ";", 0x34: "'", 0x35: "`", 0x36: ",", 0x37: ".", 0x38: "/",
+	0x39: "CapsLock", 0x46: "PrintScreen", 0x47: "ScrollLock", 0x48: "Pause",
+	0x49: "Insert", 0x4a: "Home", 0x4b: "PageUp", 0x4c: "Delete", 0x4d: "End",
+	0x4e: "PageDown", 0x4f: "Right", 0x50: "Left", 0x51: "Down", 0x52: "Up",
+	0x53: "NumLock", 0x54: "Keypad/", 0x55: "Keypad*", 0x56: "Keypad-",
+	0x57: "Keypad+", 0x58: "KeypadEnter", 0x63: "Keypad.", 0x64: "NonUS\\",
+	0x65: "Application", 0x66: "Power", 0x67: "Keypad=", 0x74: "Execute",
+	0x75: "Help", 0x76: "Menu", 0x77: "Select", 0x78: "Stop", 0x79: "Again",
+	0x7a: "Undo", 0x7b: "Cut", 0x7c: "Copy", 0x7d: "Paste", 0x7e: "Find",
+	0x7f: "Mute", 0x80: "VolumeUp", 0x81: "VolumeDown",
+	0xe0: "LeftControl", 0xe1: "LeftShift", 0xe2: "LeftAlt", 0xe3: "LeftGUI",
+	0xe4: "RightControl", 0xe5: "RightShift", 0xe6: "RightAlt", 0xe7: "RightGUI",
+}
+
+var consumerUsageNames = map[uint16]string{
+	0xb5: "ScanNextTrack", 0xb6: "ScanPreviousTrack", 0xb7: "Stop",
+	0xcd: "PlayPause", 0xe2: "Mute", 0xe9: "VolumeIncrement", 0xea: "VolumeDecrement",
+	0x183: "ALConsumerControlConfiguration", 0x18a: "ALEmailReader", 0x192: "ALCalculator",
+	0x194: "ALLocalBrowser", 0x221: "ACSearch", 0x223: "ACHome", 0x224: "ACBack",
+	0x225: "ACForward", 0x226: "ACStop", 0x227: "ACRefresh", 0x22a: "ACBookmarks",
+	0x238: "ACPan",
+}
+
+// KeyName returns the name of a HID keyboard usage, e.g. "A", "F13"
+// or "LeftAlt".
+func KeyName(key uint16) string {
+	switch {
+	case key >= 0x04 && key <= 0x1d:
+		return string(rune('A' + key - 0x04))
+	case key >= 0x1e && key <= 0x26:
+		return string(rune('1' + key - 0x1e))
+	case key == 0x27:
+		return "0"
+	case key >= 0x3a && key <= 0x45:
+		return fmt.Sprintf("F%d", key-0x3a+1)
+	case key >= 0x59 && key <= 0x61:
+		return fmt.Sprintf("Keypad%d", key-0x59+1)
+	case key == 0x62:
+		return "Keypad0"
+	case key >= KeyF13 && key <= KeyF24:
+		return fmt.Sprintf("F%d", key-KeyF13+13)
+	}
+
+	if name, ok := keyboardUsageNames[key]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Key%#02x", key)
+}
+
+// UsageName returns the name of a HID usage, including its usage page
+// in the high 16 bits.
+func UsageName(usage uint32) string {
+	page, id := uint16(usage>>16), uint16(usage)
+
+	switch page {
+	case usagePageKeyboard:
+		return KeyName(id)
+	case usagePageButton:
+		return fmt.Sprintf("Button%d", id)
+	case usagePageConsumer:
+		if name, ok := consumerUsageNames[id]; ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%#08x", usage)
+}
@@ -0,0 +1,123 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"errors"
+	"fmt"
+)
+
+// knownEvents are the button events seen in the configurations of the
+// vendor tool. Anything else could leave the button doing something
+// unexpected, or nothing at all.
+var knownEvents = map[byte]bool{
+	EventDisabled:    true,
+	EventLeftClick:   true,
+	EventRightClick:  true,
+	EventMiddleCLick: true,
+	EventBack:        true,
+	EventForward:     true,
+	EventMacroRecord: true,
+	EventSingleKey:   true,
+	EventMacroPlay:   true,
+	EventDPISwitch:   true,
+}
+
+func (self *ConfigProfile) validate(profile ProfileID) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("Profile %v: "+format, append([]interface{}{profile}, args...)...))
+	}
+
+	if self.ButtonsProfile == nil || self.LightProfile == nil || self.DPIProfile == nil {
+		fail("incomplete configuration")
+		return errs
+	}
+
+	// The profile IDs select where the reports are stored, so a
+	// mismatch would overwrite the other profile.
+	if self.ButtonsProfile.ProfileId != NewButtonsProfile(profile).ProfileId {
+		fail("buttons report has the wrong profile ID %#02x", self.ButtonsProfile.ProfileId)
+	}
+	if self.LightProfile.ProfileId != NewLightProfile(profile).ProfileId {
+		fail("light report has the wrong profile ID %#02x", self.LightProfile.ProfileId)
+	}
+	if self.DPIProfile.ProfileId != NewDPIProfile(profile).ProfileId {
+		fail("DPI report has the wrong profile ID %#02x", self.DPIProfile.ProfileId)
+	}
+
+	if !self.LightProfile.Brightness.Valid() {
+		fail("invalid light brightness %v (maximum %v)", self.LightProfile.Brightness, MaxBrightness)
+	}
+	if !self.LightProfile.BreathSpeed.Valid() {
+		fail("invalid light breath speed %v (maximum %v)", self.LightProfile.BreathSpeed, MaxBreathSpeed)
+	}
+
+	for i, b := range self.ButtonsProfile.Buttons {
+		if !knownEvents[b.EventId] {
+			fail("button %v has unknown event %#02x", i+1, b.EventId)
+		}
+	}
+
+	for i, e := range self.DPIProfile.DPI {
+		switch e.Enabled {
+		case 0:
+			continue
+		case 1:
+		default:
+			fail("DPI level %v has invalid enabled flag %v", i+1, e.Enabled)
+			continue
+		}
+
+		if d := DPI(e.X) * DPIStep; !d.Valid() {
+			fail("DPI level %v has invalid X resolution %v (between %v and %v)", i+1, d, MinDPI, MaxDPI)
+		}
+		if d := DPI(e.Y) * DPIStep; !d.Valid() {
+			fail("DPI level %v has invalid Y resolution %v (between %v and %v)", i+1, d, MinDPI, MaxDPI)
+		}
+	}
+
+	return errs
+}
+
+// Validate checks that the configuration can be safely written to the
+// device, and reports all the problems found at once.
+func (self *Config) Validate() error {
+	var errs []error
+
+	for i, p := range self.Profiles {
+		profile := ProfileID(i + 1)
+		if p == nil {
+			errs = append(errs, fmt.Errorf("Profile %v: missing", profile))
+			continue
+		}
+
+		errs = append(errs, p.validate(profile)...)
+	}
+
+	if self.PollingRate != 0 && !self.PollingRate.Valid() {
+		errs = append(errs, fmt.Errorf("Invalid polling rate: %v", int(self.PollingRate)))
+	}
+
+	return errors.Join(errs...)
+}
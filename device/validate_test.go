@@ -0,0 +1,70 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalJSONButtons(t *testing.T) {
+	tests := []struct {
+		name    string
+		buttons string
+		err     string
+	}{
+		{"defaults", ``, ""},
+		{"all listed", `, "buttons": [{"event": 1}, {"event": 2}, {"event": 3}, {"event": 5}, {"event": 16, "key": 226}, {"event": 4}, {"event": 17, "key": 21}, {"event": 8}, {"event": 19, "extended_info": 128}]`, ""},
+		{"short list", `, "buttons": [{"event": 1}, {"event": 2}]`, "2 buttons listed"},
+		{"empty list", `, "buttons": []`, "0 buttons listed"},
+		{"unknown event", `, "buttons": [{"event": 1}, {"event": 2}, {"event": 3}, {"event": 5}, {"event": 16, "key": 226}, {"event": 4}, {"event": 17, "key": 21}, {"event": 8}, {"event": 0}]`, "button 9 has unknown event"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"profiles": [{"light": {"color": "#0000ff"}` + tt.buttons + `}, {"light": {"color": "#ff0000"}}]}`
+
+			var cfg Config
+			err := json.Unmarshal([]byte(data), &cfg)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("Unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("Expected error containing %q, got none", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownEvent(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Profiles[1].ButtonsProfile.Buttons[3].EventId = 0x42
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "Profile 2: button 4 has unknown event 0x42") {
+		t.Fatalf("Expected unknown event error, got %v", err)
+	}
+}
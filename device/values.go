@@ -0,0 +1,141 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package device
+
+import (
+	"fmt"
+)
+
+// Brightness of the light, between 0 (off) and MaxBrightness.
+type Brightness byte
+
+const MaxBrightness Brightness = 3
+
+func NewBrightness(v int) (Brightness, error) {
+	if v < 0 || v > int(MaxBrightness) {
+		return 0, fmt.Errorf("Invalid brightness %v: must be between 0 and %v", v, MaxBrightness)
+	}
+
+	return Brightness(v), nil
+}
+
+func (self Brightness) Valid() bool {
+	return self <= MaxBrightness
+}
+
+// BreathSpeed of the light, between 0 (always on) and MaxBreathSpeed.
+type BreathSpeed byte
+
+const MaxBreathSpeed BreathSpeed = 3
+
+func NewBreathSpeed(v int) (BreathSpeed, error) {
+	if v < 0 || v > int(MaxBreathSpeed) {
+		return 0, fmt.Errorf("Invalid breath speed %v: must be between 0 and %v", v, MaxBreathSpeed)
+	}
+
+	return BreathSpeed(v), nil
+}
+
+func (self BreathSpeed) Valid() bool {
+	return self <= MaxBreathSpeed
+}
+
+// ProfileID identifies one of the two profiles stored in the mouse, as
+// numbered by the vendor tool: 1 or 2.
+type ProfileID byte
+
+const (
+	Profile1 ProfileID = 1
+	Profile2 ProfileID = 2
+)
+
+func NewProfileID(v int) (ProfileID, error) {
+	if v < int(Profile1) || v > int(Profile2) {
+		return 0, fmt.Errorf("Invalid profile %v: must be 1 or 2", v)
+	}
+
+	return ProfileID(v), nil
+}
+
+func (self ProfileID) Valid() bool {
+	return self == Profile1 || self == Profile2
+}
+
+// index is the zero-based profile number used in the reports.
+func (self ProfileID) index() byte {
+	return byte(self) - 1
+}
+
+// Next returns the profile following this one, wrapping around as the
+// profile button on the mouse does.
+func (self ProfileID) Next() ProfileID {
+	if self == Profile2 {
+		return Profile1
+	}
+
+	return Profile2
+}
+
+// DPIStage is one of the four DPI levels of a profile, numbered from 1.
+type DPIStage byte
+
+const MaxDPIStage DPIStage = 4
+
+func NewDPIStage(v int) (DPIStage, error) {
+	if v < 1 || v > int(MaxDPIStage) {
+		return 0, fmt.Errorf("Invalid DPI stage %v: must be between 1 and %v", v, MaxDPIStage)
+	}
+
+	return DPIStage(v), nil
+}
+
+func (self DPIStage) Valid() bool {
+	return self >= 1 && self <= MaxDPIStage
+}
+
+// DPI is a resolution setting for one of the axes. The mouse stores it
+// in units of DPIStep, so it has to be a multiple of that.
+type DPI int
+
+const (
+	DPIStep DPI = 50
+	MinDPI  DPI = DPIStep
+	MaxDPI  DPI = 8200
+)
+
+func NewDPI(v int) (DPI, error) {
+	d := DPI(v)
+	if !d.Valid() {
+		return 0, fmt.Errorf("Invalid DPI %v: must be a multiple of %v between %v and %v", v, DPIStep, MinDPI, MaxDPI)
+	}
+
+	return d, nil
+}
+
+func (self DPI) Valid() bool {
+	return self >= MinDPI && self <= MaxDPI && self%DPIStep == 0
+}
+
+func (self DPI) units() byte {
+	return byte(self / DPIStep)
+}
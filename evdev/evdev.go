@@ -0,0 +1,149 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package evdev reads the input events the kernel reports for the
+// mouse through its /dev/input/event* nodes, or from a recording of
+// them.
+package evdev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Event types and codes, from linux/input-event-codes.h.
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvRel = 0x02
+	EvMsc = 0x04
+
+	SynReport = 0x00
+
+	RelX      = 0x00
+	RelY      = 0x01
+	RelHWheel = 0x06
+	RelWheel  = 0x08
+
+	BtnLeft   = 0x110
+	BtnRight  = 0x111
+	BtnMiddle = 0x112
+	BtnSide   = 0x113
+	BtnExtra  = 0x114
+
+	KeyF13 = 183
+	KeyF24 = 194
+)
+
+// Event is a struct input_event.
+type Event struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+func (self Event) String() string {
+	return fmt.Sprintf("%v type %#02x code %#03x value %v", self.Time.Format("15:04:05.000000"), self.Type, self.Code, self.Value)
+}
+
+// rawEvent is the layout of struct input_event on 64-bit Linux, which
+// is also the format of the recordings, so that the output of
+// `cat /dev/input/eventN` can be used as such.
+type rawEvent struct {
+	Sec   int64
+	Usec  int64
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Reader decodes input events from a node or a recording.
+type Reader struct {
+	r io.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Read returns the next event, or io.EOF at the end of a recording.
+func (self *Reader) Read() (Event, error) {
+	var raw rawEvent
+	if err := binary.Read(self.r, binary.LittleEndian, &raw); err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Time:  time.Unix(raw.Sec, raw.Usec*1000),
+		Type:  raw.Type,
+		Code:  raw.Code,
+		Value: raw.Value,
+	}, nil
+}
+
+// Node is an open event node.
+type Node struct {
+	*Reader
+	f *os.File
+}
+
+func Open(path string) (*Node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Node{
+		Reader: NewReader(f),
+		f:      f,
+	}, nil
+}
+
+// Close closes the node, interrupting any Read in progress.
+func (self *Node) Close() error {
+	return self.f.Close()
+}
+
+// Write encodes an event in the recording format.
+func Write(w io.Writer, ev Event) error {
+	return binary.Write(w, binary.LittleEndian, &rawEvent{
+		Sec:   ev.Time.Unix(),
+		Usec:  int64(ev.Time.Nanosecond() / 1000),
+		Type:  ev.Type,
+		Code:  ev.Code,
+		Value: ev.Value,
+	})
+}
+
+// KeyFromHIDUsage returns the key code the kernel reports for a HID
+// keyboard usage, for the keys used as host keys (F13 to F24).
+func KeyFromHIDUsage(usage uint16) (uint16, bool) {
+	if usage < 0x68 || usage > 0x73 {
+		return 0, false
+	}
+
+	return KeyF13 + (usage - 0x68), true
+}
@@ -0,0 +1,130 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package evdev
+
+import (
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func readHex(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+}
+
+// busUSB is the bus type of USB input devices; devices re-created
+// through uinput with the same IDs are on the virtual bus instead.
+const busUSB = 0x03
+
+// Find returns the paths of the event nodes of the USB input devices
+// with the given vendor and product IDs, e.g. /dev/input/event5.
+func Find(vendor, product uint16) ([]string, error) {
+	dirs, err := filepath.Glob("/sys/class/input/event*")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		bus, err := readHex(filepath.Join(dir, "device/id/bustype"))
+		if err != nil || bus != busUSB {
+			continue
+		}
+		v, err := readHex(filepath.Join(dir, "device/id/vendor"))
+		if err != nil {
+			continue
+		}
+		p, err := readHex(filepath.Join(dir, "device/id/product"))
+		if err != nil {
+			continue
+		}
+
+		if uint16(v) == vendor && uint16(p) == product {
+			paths = append(paths, filepath.Join("/dev/input", filepath.Base(dir)))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// capabilityFiles are the event types whose codes are listed in the
+// capabilities directory of an input device in sysfs.
+var capabilityFiles = map[uint16]string{
+	EvKey: "key",
+	EvRel: "rel",
+	EvMsc: "msc",
+}
+
+// Capabilities returns the codes the event node can report, by event
+// type.
+func Capabilities(path string) (map[uint16][]uint16, error) {
+	dir := filepath.Join("/sys/class/input", filepath.Base(path), "device/capabilities")
+
+	caps := make(map[uint16][]uint16)
+	for typ, name := range capabilityFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		// A bitmap in words of the kernel's long, in hexadecimal,
+		// most significant word first.
+		words := strings.Fields(string(data))
+		for i := range words {
+			w, err := strconv.ParseUint(words[len(words)-1-i], 16, bits.UintSize)
+			if err != nil {
+				return nil, err
+			}
+
+			for b := 0; b < bits.UintSize; b++ {
+				if w&(1<<uint(b)) != 0 {
+					caps[typ] = append(caps[typ], uint16(i*bits.UintSize+b))
+				}
+			}
+		}
+	}
+
+	return caps, nil
+}
+
+// HasCode reports whether the capabilities include the given code.
+func HasCode(caps map[uint16][]uint16, typ, code uint16) bool {
+	for _, c := range caps[typ] {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
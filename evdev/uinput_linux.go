@@ -0,0 +1,132 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package evdev
+
+import (
+	"bytes"
+	"github.com/flameeyes/anker-mouse-tool/internal/ioctl"
+	"os"
+	"unsafe"
+)
+
+// From linux/uinput.h.
+const (
+	uinputPath       = "/dev/uinput"
+	uinputMaxNameLen = 80
+	busVirtual       = 0x06
+)
+
+var (
+	uiDevCreate  = ioctl.IOC(ioctl.None, 'U', 1, 0)
+	uiDevDestroy = ioctl.IOC(ioctl.None, 'U', 2, 0)
+	uiDevSetup   = ioctl.IOC(ioctl.Write, 'U', 3, unsafe.Sizeof(uinputSetup{}))
+	uiSetEvBit   = ioctl.IOC(ioctl.Write, 'U', 100, 4)
+
+	uiSetCodeBit = map[uint16]uintptr{
+		EvKey: ioctl.IOC(ioctl.Write, 'U', 101, 4),
+		EvRel: ioctl.IOC(ioctl.Write, 'U', 102, 4),
+		EvMsc: ioctl.IOC(ioctl.Write, 'U', 104, 4),
+	}
+)
+
+type uinputSetup struct {
+	Bustype      uint16
+	Vendor       uint16
+	Product      uint16
+	Version      uint16
+	Name         [uinputMaxNameLen]byte
+	FFEffectsMax uint32
+}
+
+// UInput is a virtual input device, whose events are written by the
+// program.
+type UInput struct {
+	f *os.File
+}
+
+// CreateUInput creates a virtual input device with the given name and
+// IDs, able to report the given codes (as returned by Capabilities).
+func CreateUInput(name string, vendor, product uint16, caps map[uint16][]uint16) (*UInput, error) {
+	f, err := os.OpenFile(uinputPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setupUInput(f, name, vendor, product, caps); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &UInput{f: f}, nil
+}
+
+func setupUInput(f *os.File, name string, vendor, product uint16, caps map[uint16][]uint16) error {
+	for typ, codes := range caps {
+		req, ok := uiSetCodeBit[typ]
+		if !ok || len(codes) == 0 {
+			continue
+		}
+
+		if err := ioctl.Call(f, uiSetEvBit, uintptr(typ)); err != nil {
+			return err
+		}
+		for _, c := range codes {
+			if err := ioctl.Call(f, req, uintptr(c)); err != nil {
+				return err
+			}
+		}
+	}
+
+	setup := uinputSetup{
+		Bustype: busVirtual,
+		Vendor:  vendor,
+		Product: product,
+	}
+	copy(setup.Name[:uinputMaxNameLen-1], name)
+
+	if err := ioctl.Pointer(f, uiDevSetup, unsafe.Pointer(&setup)); err != nil {
+		return err
+	}
+
+	return ioctl.Call(f, uiDevCreate, 0)
+}
+
+// Write emits an event from the virtual device. Events are only
+// delivered after an EvSyn event.
+func (self *UInput) Write(ev Event) error {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, ev); err != nil {
+		return err
+	}
+
+	_, err := self.f.Write(buf.Bytes())
+	return err
+}
+
+// Close removes the virtual device.
+func (self *UInput) Close() error {
+	ioctl.Call(self.f, uiDevDestroy, 0)
+	return self.f.Close()
+}
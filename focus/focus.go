@@ -0,0 +1,76 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package focus reports which window has the input focus on the
+// desktop, so that the mouse can be configured depending on the
+// application in use.
+package focus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Window describes the focused window. Any of the fields might be empty
+// if the desktop does not provide that information.
+type Window struct {
+	Class      string // WM_CLASS on X11, app_id or class on Wayland.
+	Title      string
+	Executable string // Absolute path of the process owning the window.
+	Pid        int
+}
+
+// Source reports the focused window every time it changes, including
+// when its title changes. The channel is closed when ctx is cancelled
+// or the connection to the desktop is lost.
+type Source interface {
+	Windows(ctx context.Context) (<-chan Window, error)
+}
+
+// Detect returns the Source matching the current desktop session.
+func Detect() (Source, error) {
+	switch {
+	case os.Getenv("SWAYSOCK") != "":
+		return NewSway(os.Getenv("SWAYSOCK")), nil
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return NewHyprland(os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")), nil
+	case os.Getenv("DISPLAY") != "":
+		return NewX11(), nil
+	}
+
+	return nil, fmt.Errorf("Unable to detect the desktop session: none of SWAYSOCK, HYPRLAND_INSTANCE_SIGNATURE or DISPLAY are set")
+}
+
+func executable(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+
+	exe, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+	if err != nil {
+		return ""
+	}
+
+	return exe
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package focus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Hyprland listens to the events socket of a Hyprland instance, and
+// queries the details of the active window on every focus change.
+type Hyprland struct {
+	dir string
+}
+
+func NewHyprland(signature string) *Hyprland {
+	// Hyprland moved its sockets from /tmp to XDG_RUNTIME_DIR in
+	// version 0.40; look in both places.
+	dir := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature)
+	if _, err := os.Stat(dir); err != nil {
+		dir = filepath.Join("/tmp/hypr", signature)
+	}
+
+	return &Hyprland{
+		dir: dir,
+	}
+}
+
+type hyprlandWindow struct {
+	Class string `json:"class"`
+	Title string `json:"title"`
+	Pid   int    `json:"pid"`
+}
+
+func (self *Hyprland) activeWindow() (Window, error) {
+	conn, err := net.Dial("unix", filepath.Join(self.dir, ".socket.sock"))
+	if err != nil {
+		return Window{}, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("j/activewindow")); err != nil {
+		return Window{}, err
+	}
+
+	payload, err := io.ReadAll(conn)
+	if err != nil {
+		return Window{}, err
+	}
+
+	var hw hyprlandWindow
+	if err := json.Unmarshal(payload, &hw); err != nil {
+		return Window{}, err
+	}
+
+	return Window{
+		Class:      hw.Class,
+		Title:      hw.Title,
+		Pid:        hw.Pid,
+		Executable: executable(hw.Pid),
+	}, nil
+}
+
+func (self *Hyprland) Windows(ctx context.Context) (<-chan Window, error) {
+	conn, err := net.Dial("unix", filepath.Join(self.dir, ".socket2.sock"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Window)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(out)
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			// Both focus and title changes are reported as
+			// activewindow>>class,title
+			if !strings.HasPrefix(scanner.Text(), "activewindow>>") {
+				continue
+			}
+
+			w, err := self.activeWindow()
+			if err != nil {
+				continue
+			}
+
+			select {
+			case out <- w:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
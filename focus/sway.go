@@ -0,0 +1,180 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package focus
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Sway subscribes to window events over the sway (i3-compatible) IPC
+// socket.
+type Sway struct {
+	socket string
+}
+
+func NewSway(socket string) *Sway {
+	return &Sway{
+		socket: socket,
+	}
+}
+
+var swayMagic = []byte("i3-ipc")
+
+const (
+	swayMessageSubscribe = 2
+	swayEventWindow      = 0x80000003
+)
+
+type swayHeader struct {
+	Magic  [6]byte
+	Length uint32
+	Type   uint32
+}
+
+type swayWindowEvent struct {
+	Change    string `json:"change"`
+	Container struct {
+		Name             string `json:"name"`
+		Focused          bool   `json:"focused"`
+		AppId            string `json:"app_id"`
+		Pid              int    `json:"pid"`
+		WindowProperties struct {
+			Class string `json:"class"`
+		} `json:"window_properties"`
+	} `json:"container"`
+}
+
+func writeSwayMessage(w io.Writer, msgType uint32, payload []byte) error {
+	hdr := swayHeader{
+		Length: uint32(len(payload)),
+		Type:   msgType,
+	}
+	copy(hdr.Magic[:], swayMagic)
+
+	if err := binary.Write(w, binary.LittleEndian, hdr); err != nil {
+		return err
+	}
+
+	_, err := w.Write(payload)
+	return err
+}
+
+func readSwayMessage(r io.Reader) (uint32, []byte, error) {
+	var hdr swayHeader
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return 0, nil, err
+	}
+
+	if string(hdr.Magic[:]) != string(swayMagic) {
+		return 0, nil, fmt.Errorf("Invalid sway IPC message magic: %q", hdr.Magic)
+	}
+
+	payload := make([]byte, hdr.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return hdr.Type, payload, nil
+}
+
+func (self *Sway) Windows(ctx context.Context) (<-chan Window, error) {
+	conn, err := net.Dial("unix", self.socket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeSwayMessage(conn, swayMessageSubscribe, []byte(`["window"]`)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	_, payload, err := readSwayMessage(conn)
+	if err == nil {
+		err = json.Unmarshal(payload, &reply)
+	}
+	if err == nil && !reply.Success {
+		err = fmt.Errorf("Unable to subscribe to sway window events")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	out := make(chan Window)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(out)
+
+		for {
+			msgType, payload, err := readSwayMessage(conn)
+			if err != nil {
+				return
+			}
+
+			if msgType != swayEventWindow {
+				continue
+			}
+
+			var ev swayWindowEvent
+			if err := json.Unmarshal(payload, &ev); err != nil {
+				continue
+			}
+
+			if ev.Change != "focus" && !(ev.Change == "title" && ev.Container.Focused) {
+				continue
+			}
+
+			w := Window{
+				Class: ev.Container.AppId,
+				Title: ev.Container.Name,
+				Pid:   ev.Container.Pid,
+			}
+			if w.Class == "" {
+				// XWayland windows have no app_id.
+				w.Class = ev.Container.WindowProperties.Class
+			}
+			w.Executable = executable(w.Pid)
+
+			select {
+			case out <- w:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package focus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// X11 follows the _NET_ACTIVE_WINDOW property of the root window, as
+// maintained by EWMH-compliant window managers.
+type X11 struct{}
+
+func NewX11() *X11 {
+	return &X11{}
+}
+
+type x11Atoms struct {
+	activeWindow xproto.Atom
+	wmName       xproto.Atom
+	wmPid        xproto.Atom
+	wmClass      xproto.Atom
+}
+
+func internAtom(conn *xgb.Conn, name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+
+	return reply.Atom, nil
+}
+
+func getProperty(conn *xgb.Conn, win xproto.Window, atom xproto.Atom) []byte {
+	reply, err := xproto.GetProperty(conn, false, win, atom, xproto.GetPropertyTypeAny, 0, 1024).Reply()
+	if err != nil || reply == nil {
+		return nil
+	}
+
+	return reply.Value
+}
+
+func (self *X11) window(conn *xgb.Conn, atoms *x11Atoms, win xproto.Window) Window {
+	var w Window
+
+	// WM_CLASS holds two NUL-terminated strings: instance and class.
+	if class := bytes.Split(getProperty(conn, win, atoms.wmClass), []byte{0}); len(class) >= 2 {
+		w.Class = string(class[1])
+	}
+
+	w.Title = string(getProperty(conn, win, atoms.wmName))
+	if w.Title == "" {
+		w.Title = string(getProperty(conn, win, xproto.AtomWmName))
+	}
+
+	if pid := getProperty(conn, win, atoms.wmPid); len(pid) >= 4 {
+		w.Pid = int(binary.LittleEndian.Uint32(pid))
+		w.Executable = executable(w.Pid)
+	}
+
+	return w
+}
+
+func (self *X11) Windows(ctx context.Context) (<-chan Window, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var atoms x11Atoms
+	for name, atom := range map[string]*xproto.Atom{
+		"_NET_ACTIVE_WINDOW": &atoms.activeWindow,
+		"_NET_WM_NAME":       &atoms.wmName,
+		"_NET_WM_PID":        &atoms.wmPid,
+		"WM_CLASS":           &atoms.wmClass,
+	} {
+		if *atom, err = internAtom(conn, name); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	err = xproto.ChangeWindowAttributesChecked(conn, root, xproto.CwEventMask, []uint32{xproto.EventMaskPropertyChange}).Check()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	out := make(chan Window)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(out)
+
+		var active xproto.Window
+		update := func() bool {
+			value := getProperty(conn, root, atoms.activeWindow)
+			if len(value) < 4 {
+				return true
+			}
+
+			win := xproto.Window(binary.LittleEndian.Uint32(value))
+			if win != active {
+				// Also follow the title of the active window.
+				xproto.ChangeWindowAttributes(conn, win, xproto.CwEventMask, []uint32{xproto.EventMaskPropertyChange})
+				active = win
+			}
+
+			select {
+			case out <- self.window(conn, &atoms, win):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !update() {
+			return
+		}
+
+		for {
+			ev, xerr := conn.WaitForEvent()
+			if ev == nil && xerr == nil {
+				// Connection closed.
+				return
+			}
+
+			pn, ok := ev.(xproto.PropertyNotifyEvent)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case pn.Window == root && pn.Atom == atoms.activeWindow,
+				pn.Window == active && (pn.Atom == atoms.wmName || pn.Atom == xproto.AtomWmName):
+				if !update() {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
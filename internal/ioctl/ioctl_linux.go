@@ -0,0 +1,96 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+// Package ioctl issues Linux ioctls on open files. They go through the
+// raw connection of the file, rather than Fd, so that the file stays
+// non-blocking and Close can interrupt a Read.
+package ioctl
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Directions of the data of a request.
+const (
+	None  = 0
+	Write = 1
+	Read  = 2
+)
+
+// IOC builds a request number, in the asm-generic layout (shared by
+// x86, arm and most other architectures).
+func IOC(dir, typ, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | typ<<8 | nr
+}
+
+// Call issues a request with an integer argument.
+func Call(f *os.File, req, arg uintptr) error {
+	_, err := control(f, func(fd uintptr) (uintptr, syscall.Errno) {
+		r, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, arg)
+		return r, errno
+	})
+	return err
+}
+
+// Pointer issues a request whose argument points to a structure.
+func Pointer(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	_, err := control(f, func(fd uintptr) (uintptr, syscall.Errno) {
+		r, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg))
+		return r, errno
+	})
+	return err
+}
+
+// Buffer issues a request on a buffer, and returns the result of the
+// call, e.g. the number of bytes transferred.
+func Buffer(f *os.File, req uintptr, buf []byte) (int, error) {
+	return control(f, func(fd uintptr) (uintptr, syscall.Errno) {
+		r, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(&buf[0])))
+		return r, errno
+	})
+}
+
+func control(f *os.File, call func(fd uintptr) (uintptr, syscall.Errno)) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var r uintptr
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		r, errno = call(fd)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return int(r), nil
+}
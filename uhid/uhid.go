@@ -0,0 +1,304 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package uhid creates virtual HID devices through the Linux uhid
+// driver (/dev/uhid), answering their feature reports from the program.
+// The kernel treats them as any other HID device, creating hidraw and
+// event nodes for them, so they can stand in for a real device in
+// tests.
+package uhid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// From linux/uhid.h.
+const (
+	eventDestroy        = 1
+	eventStart          = 2
+	eventStop           = 3
+	eventOpen           = 4
+	eventClose          = 5
+	eventOutput         = 6
+	eventGetReport      = 9
+	eventGetReportReply = 10
+	eventCreate2        = 11
+	eventInput2         = 12
+	eventSetReport      = 13
+	eventSetReportReply = 14
+
+	dataMax    = 4096
+	nameLen    = 128
+	physLen    = 64
+	uniqLen    = 64
+	eventSize  = 4 + 4372 // type and the largest request, uhid_create2_req.
+	headerSize = 4
+)
+
+// BusUSB is the bus of devices that pretend to be connected over USB.
+const BusUSB = 0x03
+
+// ReportType is the type of a report requested by the kernel.
+type ReportType byte
+
+const (
+	FeatureReport ReportType = 0
+	OutputReport  ReportType = 1
+	InputReport   ReportType = 2
+)
+
+func (self ReportType) String() string {
+	switch self {
+	case FeatureReport:
+		return "feature"
+	case OutputReport:
+		return "output"
+	case InputReport:
+		return "input"
+	}
+
+	return fmt.Sprintf("ReportType(%d)", int(self))
+}
+
+// Config describes the device to create.
+type Config struct {
+	Name string
+	// Physical path, e.g. usb-0000:00:14.0-2/input1. The kernel
+	// exposes it as HID_PHYS.
+	Phys    string
+	Bus     uint16
+	Vendor  uint32
+	Product uint32
+
+	Descriptor []byte
+}
+
+// Handler answers the requests of the kernel for the reports of the
+// device. The reports include their ID as first byte, if the device
+// uses report IDs. An error is returned to the kernel as EIO.
+type Handler interface {
+	GetReport(id byte, rtype ReportType) ([]byte, error)
+	SetReport(id byte, rtype ReportType, data []byte) error
+}
+
+// Device is a virtual HID device.
+type Device struct {
+	f *os.File
+	h Handler
+
+	mu      sync.Mutex
+	started chan struct{}
+	once    sync.Once
+	err     error // Set when reading the requests failed.
+	done    chan struct{}
+}
+
+type create2Request struct {
+	Type       uint32
+	Name       [nameLen]byte
+	Phys       [physLen]byte
+	Uniq       [uniqLen]byte
+	RDSize     uint16
+	Bus        uint16
+	Vendor     uint32
+	Product    uint32
+	Version    uint32
+	Country    uint32
+	Descriptor [dataMax]byte
+}
+
+type input2Request struct {
+	Type uint32
+	Size uint16
+	Data [dataMax]byte
+}
+
+type getReportReply struct {
+	Type uint32
+	Id   uint32
+	Err  uint16
+	Size uint16
+	Data [dataMax]byte
+}
+
+type setReportReply struct {
+	Type uint32
+	Id   uint32
+	Err  uint16
+}
+
+// newDevice creates the device on the uhid file f, and starts answering
+// its requests.
+func newDevice(f *os.File, cfg *Config, h Handler) (*Device, error) {
+	if len(cfg.Descriptor) > dataMax {
+		return nil, fmt.Errorf("Report descriptor too long: %v bytes", len(cfg.Descriptor))
+	}
+
+	req := create2Request{
+		Type:    eventCreate2,
+		RDSize:  uint16(len(cfg.Descriptor)),
+		Bus:     cfg.Bus,
+		Vendor:  cfg.Vendor,
+		Product: cfg.Product,
+	}
+	copy(req.Name[:nameLen-1], cfg.Name)
+	copy(req.Phys[:physLen-1], cfg.Phys)
+	copy(req.Descriptor[:], cfg.Descriptor)
+
+	self := &Device{
+		f:       f,
+		h:       h,
+		started: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if err := self.write(&req); err != nil {
+		return nil, err
+	}
+
+	go self.serve()
+
+	return self, nil
+}
+
+// write sends an event to the kernel, one per write call.
+func (self *Device) write(ev interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, ev); err != nil {
+		return err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	_, err := self.f.Write(buf.Bytes())
+	return err
+}
+
+// serve answers the requests of the kernel until the device is closed.
+func (self *Device) serve() {
+	defer close(self.done)
+
+	buf := make([]byte, eventSize)
+	for {
+		n, err := self.f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) && err != io.EOF {
+				self.err = err
+			}
+			return
+		}
+		if n < headerSize {
+			continue
+		}
+
+		ev := buf[:n]
+		switch binary.LittleEndian.Uint32(ev) {
+		case eventStart:
+			self.once.Do(func() { close(self.started) })
+
+		case eventGetReport:
+			// struct uhid_get_report_req: id, rnum, rtype.
+			if len(ev) < headerSize+6 {
+				continue
+			}
+			id := binary.LittleEndian.Uint32(ev[4:])
+			rnum, rtype := ev[8], ReportType(ev[9])
+
+			reply := getReportReply{Type: eventGetReportReply, Id: id}
+			data, err := self.h.GetReport(rnum, rtype)
+			if err == nil && len(data) > dataMax {
+				err = fmt.Errorf("Report %v too long: %v bytes", rnum, len(data))
+			}
+			if err != nil {
+				reply.Err = uint16(syscall.EIO)
+			} else {
+				reply.Size = uint16(copy(reply.Data[:], data))
+			}
+			self.write(&reply)
+
+		case eventSetReport:
+			// struct uhid_set_report_req: id, rnum, rtype, size, data.
+			if len(ev) < headerSize+8 {
+				continue
+			}
+			id := binary.LittleEndian.Uint32(ev[4:])
+			rnum, rtype := ev[8], ReportType(ev[9])
+			size := int(binary.LittleEndian.Uint16(ev[10:]))
+			if size > len(ev)-12 {
+				size = len(ev) - 12
+			}
+
+			reply := setReportReply{Type: eventSetReportReply, Id: id}
+			if err := self.h.SetReport(rnum, rtype, append([]byte(nil), ev[12:12+size]...)); err != nil {
+				reply.Err = uint16(syscall.EIO)
+			}
+			self.write(&reply)
+		}
+	}
+}
+
+// WaitStarted waits for a driver to take the device, after which its
+// nodes are being created.
+func (self *Device) WaitStarted(timeout time.Duration) error {
+	select {
+	case <-self.started:
+		return nil
+	case <-self.done:
+		if self.err != nil {
+			return self.err
+		}
+		return errors.New("Device closed before being started")
+	case <-time.After(timeout):
+		return fmt.Errorf("Device not started after %v", timeout)
+	}
+}
+
+// Input sends an input report, starting with its ID if the device uses
+// report IDs.
+func (self *Device) Input(data []byte) error {
+	if len(data) > dataMax {
+		return fmt.Errorf("Input report too long: %v bytes", len(data))
+	}
+
+	req := input2Request{Type: eventInput2, Size: uint16(len(data))}
+	copy(req.Data[:], data)
+
+	return self.write(&req)
+}
+
+// Close removes the device.
+func (self *Device) Close() error {
+	self.write(&struct{ Type uint32 }{eventDestroy})
+	err := self.f.Close()
+	<-self.done
+
+	return err
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package virtualmouse_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// buildTool builds one of the tools for the test, with the hidraw
+// backend only, and returns the path of the binary.
+func buildTool(t *testing.T, name string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	cmd := exec.Command("go", "build", "-tags", "nohidapi", "-o", dir, "../"+name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Unable to build %v: %v\n%s", name, err, out)
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// runTool runs a tool against the virtual mouse, failing the test if it
+// fails. The configuration it records is kept in a temporary directory.
+func runTool(t *testing.T, name string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command(buildTool(t, name), args...)
+	home := t.TempDir()
+	cmd.Env = append(os.Environ(), "HOME="+home, "XDG_CONFIG_HOME="+filepath.Join(home, ".config"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v failed: %v\n%s", name, err, out)
+	}
+}
+
+func TestLightTool(t *testing.T) {
+	mouse := start(t)
+	runTool(t, "anker-mouse-light", "-light_color", "#ff0000", "-brightness", "1")
+
+	light := mouse.State().Light
+	if light == nil || light.Color != "#ff0000" || light.Brightness != 1 {
+		t.Errorf("Light is %+v, want #ff0000 at brightness 1", light)
+	}
+}
+
+func TestProfileTool(t *testing.T) {
+	mouse := start(t)
+	runTool(t, "anker-mouse-profile", "-profile", "2")
+
+	if profile := mouse.State().Profile; profile != 2 {
+		t.Errorf("Profile is %v, want 2", profile)
+	}
+}
+
+func TestReplayerTool(t *testing.T) {
+	mouse := start(t)
+	runTool(t, "anker-mouse-replayer", "-profile1_light", "#ff00ff:2:0", "-profile2_light", "#00ffff:2:0")
+
+	s := mouse.State()
+	if s.ProfileLights[0].Color != "#ff00ff" || s.ProfileLights[1].Color != "#00ffff" {
+		t.Errorf("Profile lights are %v and %v, want #ff00ff and #00ffff", s.ProfileLights[0].Color, s.ProfileLights[1].Color)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package virtualmouse
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DescriptorsEnv names a directory with the report descriptors dumped
+// from the real mouse (see LoadDescriptors), for the virtual mouse to
+// use instead of DefaultDescriptors.
+const DescriptorsEnv = "ANKER_MOUSE_DESCRIPTORS"
+
+// The report descriptors dumped from the real mouse, checked in the
+// descriptors directory next to this file.
+//
+//go:embed descriptors
+var dumpedFS embed.FS
+
+// Descriptors are the report descriptors of the interfaces of the
+// mouse, by interface number.
+type Descriptors [][]byte
+
+// The report descriptors of the two interfaces of the mouse, as
+// reconstructed from what the tools exchange with the mouse rather
+// than dumped from it. They are only a fallback until a dump of the
+// real ones is checked in (see DefaultDescriptors): interface 0 is a five-button mouse with
+// wheel and horizontal pan, and interface 1 carries the keys the
+// buttons can be bound to (report 1), the configuration feature reports
+// (2, 3 and 4), and a vendor input report (5) sent when the profile or
+// DPI stage is changed with the buttons.
+var (
+	MouseDescriptor = []byte{
+		0x05, 0x01, // Usage Page (Generic Desktop)
+		0x09, 0x02, // Usage (Mouse)
+		0xa1, 0x01, // Collection (Application)
+		0x09, 0x01, //   Usage (Pointer)
+		0xa1, 0x00, //   Collection (Physical)
+		0x05, 0x09, //     Usage Page (Button)
+		0x19, 0x01, //     Usage Minimum (1)
+		0x29, 0x05, //     Usage Maximum (5)
+		0x15, 0x00, //     Logical Minimum (0)
+		0x25, 0x01, //     Logical Maximum (1)
+		0x95, 0x05, //     Report Count (5)
+		0x75, 0x01, //     Report Size (1)
+		0x81, 0x02, //     Input (Data, Variable, Absolute)
+		0x95, 0x01, //     Report Count (1)
+		0x75, 0x03, //     Report Size (3)
+		0x81, 0x01, //     Input (Constant)
+		0x05, 0x01, //     Usage Page (Generic Desktop)
+		0x09, 0x30, //     Usage (X)
+		0x09, 0x31, //     Usage (Y)
+		0x16, 0x01, 0x80, // Logical Minimum (-32767)
+		0x26, 0xff, 0x7f, // Logical Maximum (32767)
+		0x75, 0x10, //     Report Size (16)
+		0x95, 0x02, //     Report Count (2)
+		0x81, 0x06, //     Input (Data, Variable, Relative)
+		0x09, 0x38, //     Usage (Wheel)
+		0x15, 0x81, //     Logical Minimum (-127)
+		0x25, 0x7f, //     Logical Maximum (127)
+		0x75, 0x08, //     Report Size (8)
+		0x95, 0x01, //     Report Count (1)
+		0x81, 0x06, //     Input (Data, Variable, Relative)
+		0x05, 0x0c, //     Usage Page (Consumer)
+		0x0a, 0x38, 0x02, // Usage (AC Pan)
+		0x95, 0x01, //     Report Count (1)
+		0x81, 0x06, //     Input (Data, Variable, Relative)
+		0xc0, //         End Collection
+		0xc0, //       End Collection
+	}
+
+	VendorDescriptor = []byte{
+		0x05, 0x01, // Usage Page (Generic Desktop)
+		0x09, 0x06, // Usage (Keyboard)
+		0xa1, 0x01, // Collection (Application)
+		0x85, 0x01, //   Report ID (1)
+		0x05, 0x07, //   Usage Page (Keyboard)
+		0x19, 0xe0, //   Usage Minimum (LeftControl)
+		0x29, 0xe7, //   Usage Maximum (RightGUI)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x25, 0x01, //   Logical Maximum (1)
+		0x75, 0x01, //   Report Size (1)
+		0x95, 0x08, //   Report Count (8)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x95, 0x01, //   Report Count (1)
+		0x75, 0x08, //   Report Size (8)
+		0x81, 0x01, //   Input (Constant)
+		0x19, 0x00, //   Usage Minimum (0)
+		0x29, 0xff, //   Usage Maximum (255)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x26, 0xff, 0x00, // Logical Maximum (255)
+		0x75, 0x08, //   Report Size (8)
+		0x95, 0x06, //   Report Count (6)
+		0x81, 0x00, //   Input (Data, Array)
+		0xc0,             //       End Collection
+		0x06, 0x00, 0xff, // Usage Page (Vendor 0xff00)
+		0x09, 0x01, // Usage (1)
+		0xa1, 0x01, // Collection (Application)
+		0x85, 0x02, //   Report ID (2)
+		0x09, 0x02, //   Usage (2)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x26, 0xff, 0x00, // Logical Maximum (255)
+		0x75, 0x08, //   Report Size (8)
+		0x95, 0x0f, //   Report Count (15)
+		0xb1, 0x02, //   Feature (Data, Variable, Absolute)
+		0x85, 0x03, //   Report ID (3)
+		0x09, 0x03, //   Usage (3)
+		0x95, 0x3f, //   Report Count (63)
+		0xb1, 0x02, //   Feature (Data, Variable, Absolute)
+		0x85, 0x04, //   Report ID (4)
+		0x09, 0x04, //   Usage (4)
+		0x96, 0xff, 0x03, // Report Count (1023)
+		0xb1, 0x02, //   Feature (Data, Variable, Absolute)
+		0x85, 0x05, //   Report ID (5)
+		0x09, 0x05, //   Usage (5)
+		0x95, 0x07, //   Report Count (7)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0xc0, //       End Collection
+	}
+
+	ReconstructedDescriptors = Descriptors{MouseDescriptor, VendorDescriptor}
+)
+
+// descriptorFile returns the name of the file for the report
+// descriptor of an interface, as written by anker-mouse-doctor
+// -dump_descriptors.
+func descriptorFile(intf int) string {
+	return fmt.Sprintf("interface%d.bin", intf)
+}
+
+// LoadDescriptors reads the report descriptors dumped by
+// anker-mouse-doctor -dump_descriptors in dir, one file per interface
+// starting from interface 0.
+func LoadDescriptors(dir string) (Descriptors, error) {
+	return loadDescriptors(func(intf int) (string, []byte, error) {
+		name := filepath.Join(dir, descriptorFile(intf))
+		data, err := os.ReadFile(name)
+		return name, data, err
+	})
+}
+
+func loadDescriptors(read func(intf int) (string, []byte, error)) (Descriptors, error) {
+	var descs Descriptors
+	for intf := 0; ; intf++ {
+		name, data, err := read(intf)
+		if errors.Is(err, fs.ErrNotExist) && intf > 0 {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if _, err := device.ParseReportDescriptor(data); err != nil {
+			return nil, fmt.Errorf("Invalid report descriptor %v: %w", name, err)
+		}
+		descs = append(descs, data)
+	}
+
+	return descs, nil
+}
+
+// DumpedDescriptors returns the report descriptors dumped from the real
+// mouse that are checked in with this package, or nil if there are
+// none yet.
+func DumpedDescriptors() (Descriptors, error) {
+	descs, err := loadDescriptors(func(intf int) (string, []byte, error) {
+		name := path.Join("descriptors", descriptorFile(intf))
+		data, err := dumpedFS.ReadFile(name)
+		return name, data, err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return descs, err
+}
+
+// DefaultDescriptors returns the report descriptors to use when none
+// are given: the ones checked in (see DumpedDescriptors), or
+// ReconstructedDescriptors until a dump is checked in, in which case
+// dumped is false.
+func DefaultDescriptors() (descs Descriptors, dumped bool, err error) {
+	if descs, err = DumpedDescriptors(); err != nil || descs != nil {
+		return descs, descs != nil, err
+	}
+
+	return ReconstructedDescriptors, false, nil
+}
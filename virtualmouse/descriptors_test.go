@@ -0,0 +1,124 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package virtualmouse
+
+import (
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDescriptors(t *testing.T) {
+	dir := t.TempDir()
+	for intf, data := range ReconstructedDescriptors {
+		if err := os.WriteFile(filepath.Join(dir, descriptorFile(intf)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	descs, err := LoadDescriptors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(descs, ReconstructedDescriptors) {
+		t.Errorf("Loaded %v descriptors differing from the ones saved", len(descs))
+	}
+}
+
+func TestLoadDescriptorsMissing(t *testing.T) {
+	if _, err := LoadDescriptors(t.TempDir()); err == nil {
+		t.Error("Loaded descriptors from an empty directory")
+	}
+}
+
+func TestLoadDescriptorsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	// A Collection without its End Collection.
+	if err := os.WriteFile(filepath.Join(dir, descriptorFile(0)), []byte{0xa1, 0x01, 0x85}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadDescriptors(dir); err == nil {
+		t.Error("Loaded an invalid descriptor")
+	}
+}
+
+func TestDefaultDescriptors(t *testing.T) {
+	descs, dumped, err := DefaultDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !dumped {
+		if !reflect.DeepEqual(descs, ReconstructedDescriptors) {
+			t.Error("Without a dump, the default descriptors are not the reconstructed ones")
+		}
+		t.Log("No report descriptors dumped from the mouse are checked in")
+		return
+	}
+
+	dumpedDescs, err := DumpedDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(descs, dumpedDescs) {
+		t.Error("The default descriptors are not the dumped ones")
+	}
+}
+
+// TestReconstructedDescriptors checks that the reconstructed
+// descriptors declare the same reports as the dumped ones, which the
+// virtual mouse relies on to send its input reports.
+func TestReconstructedDescriptors(t *testing.T) {
+	dumped, err := DumpedDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dumped == nil {
+		t.Skip("No report descriptors dumped from the mouse are checked in")
+	}
+
+	if len(dumped) != len(ReconstructedDescriptors) {
+		t.Fatalf("The mouse has %v interfaces, %v reconstructed", len(dumped), len(ReconstructedDescriptors))
+	}
+
+	for intf := range dumped {
+		want, err := device.ParseReportDescriptor(dumped[intf])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := device.ParseReportDescriptor(ReconstructedDescriptors[intf])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got.Input, want.Input) {
+			t.Errorf("Interface %v: reconstructed input reports %v, dumped %v", intf, got.Input, want.Input)
+		}
+		if !reflect.DeepEqual(got.Feature, want.Feature) {
+			t.Errorf("Interface %v: reconstructed feature reports %v, dumped %v", intf, got.Feature, want.Feature)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package virtualmouse emulates the Anker mouse as a virtual HID device
+// (see package uhid), so that the device package and the tools can be
+// tried end-to-end, through the kernel and the hidraw nodes, on a Linux
+// machine with no mouse attached.
+//
+// The emulation follows the same understanding of the protocol as the
+// device package, so it cannot tell whether that understanding is
+// right: it shows that the reports go through the kernel with the sizes
+// the report descriptors declare, and what the tools make of each
+// other's writes, not that the mouse would accept them. Only sessions
+// recorded on the mouse (see device.RecordCassette) can. The answers to
+// memory reads were never captured, so they are only given with the
+// experimental features enabled (see device.ExperimentalEnv), as the
+// device package only asks for them then.
+package virtualmouse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/uhid"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"sync"
+)
+
+// Addresses in the memory of the mouse, as used by the device package.
+const (
+	activeProfileAddress  = 0x0040
+	activeDPIStageAddress = 0x0041
+	pollingRateAddress    = 0x0045
+
+	memoryMagic = 0xfafa
+)
+
+// Light is the state of the light, as set by the light report or in a
+// light profile.
+type Light struct {
+	Color       string `json:"color"`
+	Brightness  int    `json:"brightness"`
+	BreathSpeed int    `json:"breath_speed"`
+}
+
+func newLight(inverse []byte) *Light {
+	c := colorful.Color{
+		R: float64(^inverse[0]) / 255.0,
+		G: float64(^inverse[1]) / 255.0,
+		B: float64(^inverse[2]) / 255.0,
+	}
+
+	return &Light{
+		Color:       c.Hex(),
+		Brightness:  int(inverse[3]),
+		BreathSpeed: int(inverse[4]),
+	}
+}
+
+// State is the emulated state of the mouse, as the tools see it.
+type State struct {
+	Profile     int `json:"profile"`
+	DPIStage    int `json:"dpi_stage"`
+	PollingRate int `json:"polling_rate"`
+
+	// The light set with the light report, if any, rather than the
+	// one of the profile.
+	Light         *Light    `json:"light,omitempty"`
+	ProfileLights [2]*Light `json:"profile_lights"`
+
+	// Feature reports received, by ID.
+	Reports map[byte]int `json:"reports"`
+}
+
+// Model answers the feature reports of the mouse from an emulated
+// memory map: memory writes (report 2, command 2) are stored, and
+// memory reads (command 3) answered from it. The light report (command
+// 4) changes the light until the next profile switch. The other
+// reports are only recorded; the DPI and button profiles are not
+// interpreted.
+type Model struct {
+	mu sync.Mutex
+
+	// Sizes of the feature reports, including their ID.
+	features map[byte]int
+
+	memory  [0x10000]byte
+	light   []byte
+	read    []byte // Answer to the last memory read.
+	last    map[byte][]byte
+	reports [][]byte
+
+	// OnReport, if set, is called with every feature report received.
+	OnReport func(data []byte)
+}
+
+func NewModel() *Model {
+	m := &Model{
+		features: map[byte]int{2: 16, 3: 64, 4: 1024},
+		last:     make(map[byte][]byte),
+	}
+
+	// The mouse starts in the first profile and DPI stage, at 500 Hz.
+	m.memory[pollingRateAddress] = 2
+
+	return m
+}
+
+func (self *Model) SetReport(id byte, rtype uhid.ReportType, data []byte) error {
+	if rtype != uhid.FeatureReport {
+		return fmt.Errorf("Unexpected %v report %v", rtype, id)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if size, ok := self.features[id]; !ok || len(data) != size || data[0] != id {
+		return fmt.Errorf("Invalid feature report %v of %v bytes", id, len(data))
+	}
+
+	self.reports = append(self.reports, data)
+	self.last[id] = data
+	if self.OnReport != nil {
+		self.OnReport(data)
+	}
+
+	if id != 2 {
+		return nil
+	}
+
+	address := binary.LittleEndian.Uint16(data[2:])
+	length := int(binary.LittleEndian.Uint16(data[4:]))
+	payload := data[8:]
+
+	switch data[1] {
+	case 0x02: // Memory write.
+		if length > len(payload) || int(address)+length > len(self.memory) {
+			return fmt.Errorf("Invalid memory write of %v bytes at %#04x", length, address)
+		}
+		copy(self.memory[address:], payload[:length])
+
+		if address <= activeProfileAddress && int(address)+length > activeProfileAddress {
+			self.light = nil
+		}
+
+	case 0x03: // Memory read.
+		// The configuration preamble reads more than fits in the
+		// answer; only what fits is answered.
+		if length > len(payload) {
+			length = len(payload)
+		}
+		if int(address)+length > len(self.memory) {
+			return fmt.Errorf("Invalid memory read of %v bytes at %#04x", length, address)
+		}
+
+		r := make([]byte, len(data))
+		copy(r, data[:6])
+		binary.LittleEndian.PutUint16(r[6:], memoryMagic)
+		copy(r[8:], self.memory[address:int(address)+length])
+		self.read = r
+
+	case 0x04: // Light.
+		self.light = append([]byte(nil), data[2:7]...)
+	}
+
+	return nil
+}
+
+func (self *Model) GetReport(id byte, rtype uhid.ReportType) ([]byte, error) {
+	if rtype != uhid.FeatureReport {
+		return nil, fmt.Errorf("Unexpected %v report %v", rtype, id)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	size, ok := self.features[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown feature report %v", id)
+	}
+
+	if id == 2 && self.read != nil {
+		if !device.Experimental() {
+			return nil, fmt.Errorf("Memory reads are only answered with %v set", device.ExperimentalEnv)
+		}
+		return self.read, nil
+	}
+	if r, ok := self.last[id]; ok {
+		return r, nil
+	}
+
+	r := make([]byte, size)
+	r[0] = id
+	return r, nil
+}
+
+// useDescriptors takes the sizes of the feature reports from the
+// report descriptors of the interfaces.
+func (self *Model) useDescriptors(descs []*device.ReportDescriptor) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.features = make(map[byte]int)
+	for _, desc := range descs {
+		for id, size := range desc.Feature {
+			self.features[id] = size + 1
+		}
+	}
+}
+
+// Reports returns the feature reports received, in order.
+func (self *Model) Reports() [][]byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return append([][]byte(nil), self.reports...)
+}
+
+func (self *Model) State() *State {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	s := &State{
+		Profile:  int(self.memory[activeProfileAddress]) + 1,
+		DPIStage: int(self.memory[activeDPIStageAddress]) + 1,
+		Reports:  make(map[byte]int),
+	}
+
+	if interval := self.memory[pollingRateAddress]; interval != 0 {
+		s.PollingRate = 1000 / int(interval)
+	}
+
+	if self.light != nil {
+		s.Light = newLight(self.light)
+	}
+	s.ProfileLights[0] = newLight(self.memory[0x0881:])
+	s.ProfileLights[1] = newLight(self.memory[0x1181:])
+
+	for _, r := range self.reports {
+		s.Reports[r[0]]++
+	}
+
+	return s
+}
+
+// switchProfile emulates the profile button: it selects the other
+// profile, dropping the light set with the light report.
+func (self *Model) switchProfile() byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.memory[activeProfileAddress] ^= 1
+	self.light = nil
+
+	return self.memory[activeProfileAddress]
+}
+
+// switchDPIStage emulates the DPI button, going through all four
+// stages.
+func (self *Model) switchDPIStage() byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.memory[activeDPIStageAddress] = (self.memory[activeDPIStageAddress] + 1) % 4
+
+	return self.memory[activeDPIStageAddress]
+}
@@ -0,0 +1,193 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package virtualmouse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/uhid"
+	"time"
+)
+
+// How long to wait for the kernel to create the nodes of the mouse.
+const startTimeout = 5 * time.Second
+
+// Mouse is the emulated mouse, with its interfaces.
+type Mouse struct {
+	*Model
+
+	interfaces []*uhid.Device
+	inputs     []map[byte]int // Sizes of the input reports, by interface.
+}
+
+// Start creates the virtual mouse, with the interfaces described by
+// descs (usually DefaultDescriptors, or those dumped from the real mouse
+// loaded with LoadDescriptors), answering the feature reports from m,
+// and waits for its hidraw nodes to appear. A real mouse should not be
+// connected at the same time, as the tools would not tell them apart.
+func Start(m *Model, descs Descriptors) (*Mouse, error) {
+	var parsed []*device.ReportDescriptor
+	for intf, data := range descs {
+		desc, err := device.ParseReportDescriptor(data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid report descriptor for interface %v: %w", intf, err)
+		}
+		parsed = append(parsed, desc)
+	}
+	m.useDescriptors(parsed)
+
+	cfg := uhid.Config{
+		Name:    "Holtek Anker mouse (virtual)",
+		Bus:     uhid.BusUSB,
+		Vendor:  device.HoltekVendorId,
+		Product: device.AnkerMouseDeviceId,
+	}
+
+	mouse := &Mouse{Model: m}
+	for intf, data := range descs {
+		cfg.Phys = fmt.Sprintf("anker-mouse-virtual/input%d", intf)
+		cfg.Descriptor = data
+
+		d, err := uhid.Create(&cfg, m)
+		if err != nil {
+			mouse.Close()
+			return nil, err
+		}
+		mouse.interfaces = append(mouse.interfaces, d)
+		mouse.inputs = append(mouse.inputs, parsed[intf].Input)
+	}
+
+	if err := mouse.wait(); err != nil {
+		mouse.Close()
+		return nil, err
+	}
+
+	return mouse, nil
+}
+
+// wait waits for the kernel to start all the interfaces, and to create
+// their hidraw nodes.
+func (self *Mouse) wait() error {
+	deadline := time.Now().Add(startTimeout)
+
+	for _, d := range self.interfaces {
+		if err := d.WaitStarted(time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		descs, err := device.InterfaceDescriptors()
+		if err == nil && len(descs) == len(self.interfaces) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("Found %v interfaces", len(descs))
+			}
+			return fmt.Errorf("The hidraw nodes of the virtual mouse did not appear: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Close removes the virtual mouse, as if unplugged.
+func (self *Mouse) Close() {
+	for _, d := range self.interfaces {
+		d.Close()
+	}
+}
+
+// input sends an input report, starting with its ID unless it is 0,
+// through the interface declaring it. The layout of the reports sent
+// is the one of ReconstructedDescriptors, so this fails if the
+// descriptors in use declare the report with a different size.
+func (self *Mouse) input(id byte, r []byte) error {
+	size := len(r)
+	if id != 0 {
+		size--
+	}
+
+	for intf, inputs := range self.inputs {
+		if inputs[id] == size {
+			return self.interfaces[intf].Input(r)
+		}
+	}
+
+	return fmt.Errorf("No interface of the virtual mouse declares input report %v of %v bytes", id, size)
+}
+
+// Move sends a report of the pointer interface, with the buttons
+// pressed (bit 0 for the left button, up to bit 4) and the relative
+// movement.
+func (self *Mouse) Move(buttons byte, x, y, wheel int) error {
+	r := make([]byte, 7)
+	r[0] = buttons & 0x1f
+	binary.LittleEndian.PutUint16(r[1:], uint16(int16(x)))
+	binary.LittleEndian.PutUint16(r[3:], uint16(int16(y)))
+	r[5] = byte(int8(wheel))
+
+	return self.input(0, r)
+}
+
+// Key sends the press, or release, of a key as it would be sent for a
+// button bound to it, by HID usage (e.g. device.KeyF13).
+func (self *Mouse) Key(usage uint16, pressed bool) error {
+	r := make([]byte, 9)
+	r[0] = 0x01
+	switch {
+	case !pressed:
+	case usage >= 0xe0 && usage <= 0xe7:
+		// Modifiers are a bitmap rather than keys.
+		r[1] = 1 << (usage - 0xe0)
+	default:
+		r[3] = byte(usage)
+	}
+
+	return self.input(0x01, r)
+}
+
+// notify sends the vendor input report the tools read the state after.
+// Its content is not known, so it only carries the changed address and
+// value.
+func (self *Mouse) notify(address, value byte) error {
+	r := make([]byte, 8)
+	r[0] = 0x05
+	r[1] = address
+	r[2] = value
+
+	return self.input(0x05, r)
+}
+
+// PressProfileButton switches profile, as the button on the mouse does.
+func (self *Mouse) PressProfileButton() error {
+	return self.notify(activeProfileAddress, self.switchProfile())
+}
+
+// PressDPIButton selects the next DPI stage, as the button on the
+// mouse does.
+func (self *Mouse) PressDPIButton() error {
+	return self.notify(activeDPIStageAddress, self.switchDPIStage())
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 Diego Elio Pettenò <flameeyes@flameeyes.com>
+//
+// Permission is hereby granted, free of charge, to any person
+// obtaining a copy of this software and associated documentation
+// files (the "Software"), to deal in the Software without
+// restriction, including without limitation the rights to use, copy,
+// modify, merge, publish, distribute, sublicense, and/or sell copies
+// of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS
+// BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN
+// ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+
+package virtualmouse_test
+
+import (
+	"context"
+	"errors"
+	"github.com/flameeyes/anker-mouse-tool/device"
+	"github.com/flameeyes/anker-mouse-tool/virtualmouse"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+)
+
+// start connects a virtual mouse for the duration of the test, with the
+// descriptors in ANKER_MOUSE_DESCRIPTORS if set, and the default ones
+// otherwise. The test is skipped if
+// virtual HID devices cannot be created, usually because it is not
+// running as root.
+func start(t *testing.T) *virtualmouse.Mouse {
+	t.Helper()
+
+	var descs virtualmouse.Descriptors
+	var err error
+	if dir := os.Getenv(virtualmouse.DescriptorsEnv); dir != "" {
+		descs, err = virtualmouse.LoadDescriptors(dir)
+	} else {
+		var dumped bool
+		descs, dumped, err = virtualmouse.DefaultDescriptors()
+		if !dumped {
+			t.Logf("Using reconstructed report descriptors, so the tools are only checked against their own understanding of the mouse; check in a dump under virtualmouse/descriptors, or set %v", virtualmouse.DescriptorsEnv)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mouse, err := virtualmouse.Start(virtualmouse.NewModel(), descs)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		t.Skipf("Unable to create a virtual mouse: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mouse.Close)
+
+	// hidapi only sees the virtual mouse if built on hidraw.
+	if os.Getenv(device.BackendEnv) == "" {
+		t.Setenv(device.BackendEnv, "hidraw")
+	}
+
+	return mouse
+}
+
+func open(t *testing.T) *device.Device {
+	t.Helper()
+
+	dev, err := device.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dev.Close)
+
+	return dev
+}
+
+func TestSetLight(t *testing.T) {
+	mouse := start(t)
+	dev := open(t)
+
+	if err := dev.SetLight(colorful.Color{R: 1}, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	light := mouse.State().Light
+	if light == nil || light.Color != "#ff0000" || light.Brightness != 3 {
+		t.Errorf("Light is %+v, want #ff0000 at brightness 3", light)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	mouse := start(t)
+	dev := open(t)
+
+	if err := dev.SetProfile(device.Profile2); err != nil {
+		t.Fatal(err)
+	}
+
+	if profile := mouse.State().Profile; profile != 2 {
+		t.Errorf("Profile is %v, want 2", profile)
+	}
+}
+
+func TestConfigWrite(t *testing.T) {
+	mouse := start(t)
+	dev := open(t)
+
+	cfg := device.NewConfig()
+	cfg.Profiles[1].LightProfile.SetColor(colorful.Color{G: 1})
+	if err := cfg.Write(dev); err != nil {
+		t.Fatal(err)
+	}
+
+	if light := mouse.State().ProfileLights[1]; light.Color != "#00ff00" {
+		t.Errorf("Light of profile 2 is %v, want #00ff00", light.Color)
+	}
+}
+
+// waitEvent waits for an event matching want, skipping the events
+// before it, and fails the test if none arrives in time.
+func waitEvent(t *testing.T, events <-chan device.Event, want device.Event) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("Events ended before %v", want)
+			}
+			ev.Time = time.Time{}
+			if ev == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("No %v event", want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	mouse := start(t)
+	dev := open(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := dev.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mouse.Move(0x01, 10, -5, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, device.Event{Kind: device.MotionEvent, X: 10, Y: -5})
+	waitEvent(t, events, device.Event{Kind: device.ButtonEvent, Button: 1, Usage: 0x90001, Pressed: true})
+
+	if err := mouse.Key(device.KeyF13, true); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, device.Event{Kind: device.KeyEvent, Usage: 0x70000 | uint32(device.KeyF13), Pressed: true})
+}
+
+// TestActiveProfile only shows that the model and the device package
+// agree on the guessed answers to memory reads, not that the mouse
+// answers that way.
+func TestActiveProfile(t *testing.T) {
+	mouse := start(t)
+	dev := open(t)
+
+	if _, err := dev.ActiveProfile(); !errors.Is(err, device.ErrExperimental) {
+		t.Fatalf("ActiveProfile without %v returned %v, want ErrExperimental", device.ExperimentalEnv, err)
+	}
+
+	t.Setenv(device.ExperimentalEnv, "1")
+	if err := mouse.PressProfileButton(); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := dev.ActiveProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile != device.Profile2 {
+		t.Errorf("Active profile is %v, want %v", profile, device.Profile2)
+	}
+}